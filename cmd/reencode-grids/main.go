@@ -0,0 +1,107 @@
+// Command reencode-grids rewrites grid states stored in the legacy text format
+// into the current packed format.
+//
+// It walks the games table in small batches and only replaces a grid state if it
+// was not changed in the meantime, so it is safe to run in the background next to
+// a live server:
+//
+//	go run ./cmd/reencode-grids -batch-size 500 -pause 200ms
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"minesweeper/internal/db"
+	"minesweeper/internal/models"
+	"minesweeper/internal/storage"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	batchSize := flag.Int64("batch-size", 500, "amount of games re-encoded per batch")
+	pause := flag.Duration("pause", 200*time.Millisecond, "pause between batches to keep load on the database low")
+	dryRun := flag.Bool("dry-run", false, "only report how many games would be re-encoded")
+	flag.Parse()
+
+	if err := godotenv.Load(".env"); err != nil {
+		log.Println(".env file not found. Continuing with environment variables.")
+	}
+
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		log.Fatal("DATABASE_URL environment variable not set")
+	}
+
+	database, err := storage.Open(databaseURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer database.Close()
+
+	reencoded, skipped, err := reencodeGrids(context.Background(), database.Repository(), *batchSize, *pause, *dryRun)
+	if err != nil {
+		log.Fatalf("Re-encoding stopped after %d games: %v", reencoded, err)
+	}
+
+	fmt.Printf("Re-encoded %d games, skipped %d corrupt games or games changed during the run.\n", reencoded, skipped)
+}
+
+func reencodeGrids(ctx context.Context, queries storage.GameRepository, batchSize int64, pause time.Duration, dryRun bool) (reencoded int, skipped int, err error) {
+	var lastId int64
+
+	for {
+		legacyGames, err := queries.ListLegacyGridStates(ctx, db.ListLegacyGridStatesParams{
+			Id:    lastId,
+			Limit: batchSize,
+		})
+		if err != nil {
+			return reencoded, skipped, fmt.Errorf("failed to list legacy grid states: %w", err)
+		}
+
+		if len(legacyGames) == 0 {
+			return reencoded, skipped, nil
+		}
+
+		for _, legacyGame := range legacyGames {
+			lastId = legacyGame.Id
+
+			if dryRun {
+				reencoded++
+				continue
+			}
+
+			grid, err := models.DecodeGameGrid(legacyGame.GridState, int(legacyGame.GridSize))
+			if err != nil {
+				// corrupt rows are left untouched so they can still be inspected by hand
+				log.Printf("Skipping game %d: %v", legacyGame.Id, err)
+				skipped++
+				continue
+			}
+
+			// the old grid state is part of the condition, a move made in the meantime wins
+			affected, err := queries.ReplaceGameGridState(ctx, db.ReplaceGameGridStateParams{
+				NewGridState: models.EncodeGameGrid(grid),
+				Id:           legacyGame.Id,
+				OldGridState: legacyGame.GridState,
+			})
+			if err != nil {
+				return reencoded, skipped, fmt.Errorf("failed to update game %d: %w", legacyGame.Id, err)
+			}
+
+			if affected == 0 {
+				skipped++
+				continue
+			}
+			reencoded++
+		}
+
+		log.Printf("Re-encoded %d games so far, last game id: %d", reencoded, lastId)
+
+		time.Sleep(pause)
+	}
+}
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+-- games created before the rule existed keep the strict semantics they were played with
+ALTER TABLE games ADD COLUMN win_rule TEXT NOT NULL DEFAULT 'strict'
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE games DROP COLUMN win_rule
+-- +goose StatementEnd
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the admin list of games is paged by (created_at, id), see ListFilteredGames
+CREATE INDEX games_created_at_id_idx ON games (created_at, id)
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX games_created_at_id_idx
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the session that started the game, games created before it was recorded have none
+ALTER TABLE games ADD COLUMN session_id TEXT
+-- +goose StatementEnd
+-- +goose StatementBegin
+-- set when an admin or the retention job ends an unfinished game, it is then left out of the win and loss stats
+ALTER TABLE games ADD COLUMN abandoned_at TIMESTAMP
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE games DROP COLUMN abandoned_at
+-- +goose StatementEnd
+-- +goose StatementBegin
+ALTER TABLE games DROP COLUMN session_id
+-- +goose StatementEnd
@@ -0,0 +1,53 @@
+-- +goose Up
+-- +goose StatementBegin
+-- SQLite can't change a foreign key in place, so the moves are copied into a new table.
+-- Moves of games that no longer exist are dropped, SQLite didn't enforce the foreign key before.
+CREATE TABLE
+    moves_cascading (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        game_id INTEGER NOT NULL,
+        move_type VARCHAR(255) NOT NULL,
+        row INTEGER NOT NULL,
+        col INTEGER NOT NULL,
+        create_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        FOREIGN KEY (game_id) REFERENCES games (id) ON DELETE CASCADE
+    )
+-- +goose StatementEnd
+-- +goose StatementBegin
+INSERT INTO moves_cascading (id, game_id, move_type, row, col, create_at)
+SELECT id, game_id, move_type, row, col, create_at FROM moves
+WHERE game_id IN (SELECT id FROM games)
+-- +goose StatementEnd
+-- +goose StatementBegin
+DROP TABLE moves
+-- +goose StatementEnd
+-- +goose StatementBegin
+ALTER TABLE moves_cascading RENAME TO moves
+-- +goose StatementEnd
+-- +goose StatementBegin
+-- the cascade looks up the moves of every deleted game
+CREATE INDEX moves_game_id_idx ON moves (game_id)
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+CREATE TABLE
+    moves_restricting (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        game_id INTEGER NOT NULL,
+        move_type VARCHAR(255) NOT NULL,
+        row INTEGER NOT NULL,
+        col INTEGER NOT NULL,
+        create_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        FOREIGN KEY (game_id) REFERENCES games (id)
+    )
+-- +goose StatementEnd
+-- +goose StatementBegin
+INSERT INTO moves_restricting (id, game_id, move_type, row, col, create_at)
+SELECT id, game_id, move_type, row, col, create_at FROM moves
+-- +goose StatementEnd
+-- +goose StatementBegin
+DROP TABLE moves
+-- +goose StatementEnd
+-- +goose StatementBegin
+ALTER TABLE moves_restricting RENAME TO moves
+-- +goose StatementEnd
@@ -0,0 +1,132 @@
+// Package migrations embeds the goose migrations of this directory, so the server
+// can bring any database up to date on start without goose being installed.
+//
+// The SQLite migrations live in this directory, their PostgreSQL counterparts with
+// the same versions live in the postgres directory.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/pressly/goose/v3"
+)
+
+//go:embed *.sql postgres/*.sql
+var FS embed.FS
+
+// Dialects supported by the embedded migrations, named after the schemes accepted in DATABASE_URL.
+const (
+	DialectSQLite   = "sqlite"
+	DialectPostgres = "postgres"
+)
+
+// ErrSchemaTooNew is returned when the database was migrated by a newer build than the running one.
+// Starting anyway could corrupt data written by the newer schema, so it is treated as fatal.
+var ErrSchemaTooNew = errors.New("database schema is newer than the embedded migrations")
+
+// ErrSchemaOutdated is returned by Check when there are migrations left to apply.
+var ErrSchemaOutdated = errors.New("database schema is not up to date")
+
+// SchemaVersion describes the version recorded in the database and the newest embedded migration.
+type SchemaVersion struct {
+	Current int64
+	Latest  int64
+}
+
+func newProvider(db *sql.DB, dialect string) (*goose.Provider, error) {
+	var (
+		gooseDialect goose.Dialect
+		migrations   fs.FS
+		err          error
+	)
+
+	switch dialect {
+	case DialectSQLite:
+		gooseDialect, migrations = goose.DialectSQLite3, FS
+	case DialectPostgres:
+		gooseDialect = goose.DialectPostgres
+		migrations, err = fs.Sub(FS, "postgres")
+	default:
+		return nil, fmt.Errorf("no migrations for database dialect %q", dialect)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to load embedded migrations: %w", err)
+	}
+
+	provider, err := goose.NewProvider(gooseDialect, db, migrations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load embedded migrations: %w", err)
+	}
+
+	return provider, nil
+}
+
+func schemaVersion(ctx context.Context, provider *goose.Provider) (SchemaVersion, error) {
+	current, err := provider.GetDBVersion(ctx)
+	if err != nil {
+		return SchemaVersion{}, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	sources := provider.ListSources()
+	latest := sources[len(sources)-1].Version
+
+	if current > latest {
+		return SchemaVersion{Current: current, Latest: latest}, fmt.Errorf("%w: database is at version %d, newest migration is %d", ErrSchemaTooNew, current, latest)
+	}
+
+	return SchemaVersion{Current: current, Latest: latest}, nil
+}
+
+// Up applies every pending migration and returns the schema version before and after migrating.
+// A database that is ahead of the embedded migrations is left untouched and ErrSchemaTooNew is returned.
+func Up(ctx context.Context, db *sql.DB, dialect string) (before SchemaVersion, after SchemaVersion, err error) {
+	provider, err := newProvider(db, dialect)
+	if err != nil {
+		return SchemaVersion{}, SchemaVersion{}, err
+	}
+
+	before, err = schemaVersion(ctx, provider)
+	if err != nil {
+		return before, before, err
+	}
+
+	if _, err := provider.Up(ctx); err != nil {
+		return before, before, fmt.Errorf("failed to apply migrations: %w", err)
+	}
+
+	after, err = schemaVersion(ctx, provider)
+	if err != nil {
+		return before, after, err
+	}
+
+	if after.Current != after.Latest {
+		return before, after, fmt.Errorf("%w: database is at version %d after migrating, expected %d", ErrSchemaOutdated, after.Current, after.Latest)
+	}
+
+	return before, after, nil
+}
+
+// Check verifies that the database schema matches the newest embedded migration without changing anything.
+func Check(ctx context.Context, db *sql.DB, dialect string) (SchemaVersion, error) {
+	provider, err := newProvider(db, dialect)
+	if err != nil {
+		return SchemaVersion{}, err
+	}
+
+	version, err := schemaVersion(ctx, provider)
+	if err != nil {
+		return version, err
+	}
+
+	if version.Current != version.Latest {
+		return version, fmt.Errorf("%w: database is at version %d, newest migration is %d", ErrSchemaOutdated, version.Current, version.Latest)
+	}
+
+	return version, nil
+}
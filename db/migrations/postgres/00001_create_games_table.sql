@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE
+    games (
+        id BIGSERIAL PRIMARY KEY,
+        uuid TEXT NOT NULL DEFAULT (gen_random_uuid()::TEXT),
+        grid_size INTEGER NOT NULL,
+        mines_amount INTEGER NOT NULL,
+        game_failed BOOLEAN NOT NULL DEFAULT FALSE,
+        game_won BOOLEAN NOT NULL DEFAULT FALSE,
+        grid_state TEXT NOT NULL,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    )
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE games
+-- +goose StatementEnd
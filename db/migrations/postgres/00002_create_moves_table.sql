@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE
+    moves (
+        id BIGSERIAL PRIMARY KEY,
+        game_id BIGINT NOT NULL,
+        move_type VARCHAR(255) NOT NULL,
+        "row" INTEGER NOT NULL,
+        col INTEGER NOT NULL,
+        create_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        FOREIGN KEY (game_id) REFERENCES games (id)
+    )
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE moves
+-- +goose StatementEnd
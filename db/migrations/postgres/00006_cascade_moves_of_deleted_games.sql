@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE moves DROP CONSTRAINT moves_game_id_fkey
+-- +goose StatementEnd
+-- +goose StatementBegin
+ALTER TABLE moves ADD CONSTRAINT moves_game_id_fkey FOREIGN KEY (game_id) REFERENCES games (id) ON DELETE CASCADE
+-- +goose StatementEnd
+-- +goose StatementBegin
+-- the cascade looks up the moves of every deleted game
+CREATE INDEX moves_game_id_idx ON moves (game_id)
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX moves_game_id_idx
+-- +goose StatementEnd
+-- +goose StatementBegin
+ALTER TABLE moves DROP CONSTRAINT moves_game_id_fkey
+-- +goose StatementEnd
+-- +goose StatementBegin
+ALTER TABLE moves ADD CONSTRAINT moves_game_id_fkey FOREIGN KEY (game_id) REFERENCES games (id)
+-- +goose StatementEnd
@@ -1,8 +1,8 @@
 -- name: CreateGame :one 
 INSERT INTO
-    games (grid_size, mines_amount, grid_state)
+    games (grid_size, mines_amount, grid_state, win_rule, session_id)
 VALUES
-    (?, ?, ?) RETURNING *;
+    (?, ?, ?, ?, ?) RETURNING *;
 
 -- name: InsertMove :one
 INSERT INTO
@@ -41,17 +41,115 @@ SELECT
 FROM
     games;
 
--- name: UpdateGameGridStateById :exec
+-- name: ListFilteredGames :many
+-- Lists the games of the admin page. Every filter is optional, NULL matches all games, and the filters are
+-- the same as in CountFilteredGames. The sort argument is one of <column>_asc or <column>_desc.
+-- Sorted by created_at, the list is paged from the optional cursor on instead of by the offset: towards older
+-- games sorted descending, towards newer ones sorted ascending, ties are broken by the id in the same direction.
+-- Other sorts break ties by the newest id. The creation bounds and the cursor are compared as text, in the
+-- format SQLite stores CURRENT_TIMESTAMP in. The sort is selected into params first, sqlc leaves the arguments of an ORDER BY alone.
+-- sqlc numbers the OFFSET before the LIMIT, so page_offset comes before page_size in the params.
+SELECT
+    games.id, games.uuid, games.grid_size, games.mines_amount, games.game_failed, games.game_won, games.created_at,
+    games.abandoned_at
+FROM
+    games
+    CROSS JOIN (SELECT CAST(sqlc.arg(sort) AS TEXT) AS sort) AS params
+WHERE
+    (CAST(sqlc.narg(status) AS TEXT) IS NULL
+        OR CAST(sqlc.narg(status) AS TEXT) = CASE WHEN games.game_won THEN 'won' WHEN games.abandoned_at IS NOT NULL THEN 'abandoned' WHEN games.game_failed THEN 'lost' ELSE 'in_progress' END)
+    AND (games.grid_size >= sqlc.narg(min_grid_size) OR sqlc.narg(min_grid_size) IS NULL)
+    AND (games.grid_size <= sqlc.narg(max_grid_size) OR sqlc.narg(max_grid_size) IS NULL)
+    AND (games.mines_amount >= sqlc.narg(min_mines) OR sqlc.narg(min_mines) IS NULL)
+    AND (games.mines_amount <= sqlc.narg(max_mines) OR sqlc.narg(max_mines) IS NULL)
+    AND (games.created_at >= CAST(sqlc.narg(created_from) AS TEXT) OR sqlc.narg(created_from) IS NULL)
+    AND (games.created_at < CAST(sqlc.narg(created_before) AS TEXT) OR sqlc.narg(created_before) IS NULL)
+    AND (games.uuid LIKE CAST(sqlc.narg(uuid_prefix) AS TEXT) || '%' OR sqlc.narg(uuid_prefix) IS NULL)
+    AND (CAST(sqlc.narg(cursor_created_at) AS TEXT) IS NULL
+        OR (params.sort = 'created_at_desc'
+            AND (games.created_at, games.id) < (CAST(sqlc.narg(cursor_created_at) AS TEXT), CAST(sqlc.narg(cursor_id) AS INTEGER)))
+        OR (params.sort = 'created_at_asc'
+            AND (games.created_at, games.id) > (CAST(sqlc.narg(cursor_created_at) AS TEXT), CAST(sqlc.narg(cursor_id) AS INTEGER))))
+ORDER BY
+    CASE WHEN params.sort = 'id_asc' THEN games.id END ASC,
+    CASE WHEN params.sort = 'id_desc' THEN games.id END DESC,
+    CASE WHEN params.sort = 'uuid_asc' THEN games.uuid END ASC,
+    CASE WHEN params.sort = 'uuid_desc' THEN games.uuid END DESC,
+    CASE WHEN params.sort = 'grid_size_asc' THEN games.grid_size END ASC,
+    CASE WHEN params.sort = 'grid_size_desc' THEN games.grid_size END DESC,
+    CASE WHEN params.sort = 'mines_amount_asc' THEN games.mines_amount END ASC,
+    CASE WHEN params.sort = 'mines_amount_desc' THEN games.mines_amount END DESC,
+    CASE WHEN params.sort = 'status_asc' THEN CASE WHEN games.game_won THEN 'won' WHEN games.abandoned_at IS NOT NULL THEN 'abandoned' WHEN games.game_failed THEN 'lost' ELSE 'in_progress' END END ASC,
+    CASE WHEN params.sort = 'status_desc' THEN CASE WHEN games.game_won THEN 'won' WHEN games.abandoned_at IS NOT NULL THEN 'abandoned' WHEN games.game_failed THEN 'lost' ELSE 'in_progress' END END DESC,
+    CASE WHEN params.sort = 'created_at_asc' THEN games.created_at END ASC,
+    CASE WHEN params.sort = 'created_at_asc' THEN games.id END ASC,
+    CASE WHEN params.sort = 'created_at_desc' THEN games.created_at END DESC,
+    games.id DESC
+LIMIT sqlc.arg(page_size) OFFSET sqlc.arg(page_offset);
+
+-- name: CountFilteredGames :one
+SELECT
+    COUNT(games.id) AS count
+FROM
+    games
+WHERE
+    (CAST(sqlc.narg(status) AS TEXT) IS NULL
+        OR CAST(sqlc.narg(status) AS TEXT) = CASE WHEN games.game_won THEN 'won' WHEN games.abandoned_at IS NOT NULL THEN 'abandoned' WHEN games.game_failed THEN 'lost' ELSE 'in_progress' END)
+    AND (games.grid_size >= sqlc.narg(min_grid_size) OR sqlc.narg(min_grid_size) IS NULL)
+    AND (games.grid_size <= sqlc.narg(max_grid_size) OR sqlc.narg(max_grid_size) IS NULL)
+    AND (games.mines_amount >= sqlc.narg(min_mines) OR sqlc.narg(min_mines) IS NULL)
+    AND (games.mines_amount <= sqlc.narg(max_mines) OR sqlc.narg(max_mines) IS NULL)
+    AND (games.created_at >= CAST(sqlc.narg(created_from) AS TEXT) OR sqlc.narg(created_from) IS NULL)
+    AND (games.created_at < CAST(sqlc.narg(created_before) AS TEXT) OR sqlc.narg(created_before) IS NULL)
+    AND (games.uuid LIKE CAST(sqlc.narg(uuid_prefix) AS TEXT) || '%' OR sqlc.narg(uuid_prefix) IS NULL);
+
+-- name: ExportFilteredGames :many
+-- Pages through the filtered games by id for the exports, optionally only the games with the uuids of a JSON
+-- array. The last move is joined for the duration of the games, games without moves have no last_move_at.
+-- The uuids are selected into params first, sqlc leaves the arguments of a table function alone.
+SELECT
+    games.id, games.uuid, games.grid_size, games.mines_amount, games.game_failed, games.game_won, games.grid_state,
+    games.created_at, games.win_rule, games.session_id, games.abandoned_at,
+    (SELECT COUNT(*) FROM moves WHERE moves.game_id = games.id) AS moves_count,
+    last_move.create_at AS last_move_at
+FROM
+    games
+    CROSS JOIN (SELECT CAST(sqlc.narg(uuids) AS TEXT) AS uuids) AS params
+    LEFT JOIN moves AS last_move ON last_move.id = (SELECT MAX(moves.id) FROM moves WHERE moves.game_id = games.id)
+WHERE
+    (CAST(sqlc.narg(status) AS TEXT) IS NULL
+        OR CAST(sqlc.narg(status) AS TEXT) = CASE WHEN games.game_won THEN 'won' WHEN games.abandoned_at IS NOT NULL THEN 'abandoned' WHEN games.game_failed THEN 'lost' ELSE 'in_progress' END)
+    AND (games.grid_size >= sqlc.narg(min_grid_size) OR sqlc.narg(min_grid_size) IS NULL)
+    AND (games.grid_size <= sqlc.narg(max_grid_size) OR sqlc.narg(max_grid_size) IS NULL)
+    AND (games.mines_amount >= sqlc.narg(min_mines) OR sqlc.narg(min_mines) IS NULL)
+    AND (games.mines_amount <= sqlc.narg(max_mines) OR sqlc.narg(max_mines) IS NULL)
+    AND (games.created_at >= CAST(sqlc.narg(created_from) AS TEXT) OR sqlc.narg(created_from) IS NULL)
+    AND (games.created_at < CAST(sqlc.narg(created_before) AS TEXT) OR sqlc.narg(created_before) IS NULL)
+    AND (games.uuid LIKE CAST(sqlc.narg(uuid_prefix) AS TEXT) || '%' OR sqlc.narg(uuid_prefix) IS NULL)
+    AND (params.uuids IS NULL OR games.uuid IN (SELECT value FROM json_each(params.uuids)))
+    AND games.id > sqlc.arg(after_id)
+ORDER BY
+    games.id
+LIMIT
+    sqlc.arg(page_size);
+
+-- name: UpdateGameGridStateById :execrows
+-- Stores the grid of an unfinished game only if it still has the old grid state it was loaded with,
+-- so a concurrent move or abandon is never overwritten. Returns 0 rows when the game was changed meanwhile.
 UPDATE
     games
 SET
-    game_failed = ?,
-    game_won = ?,
-    grid_state = ?
+    game_failed = sqlc.arg(game_failed),
+    game_won = sqlc.arg(game_won),
+    grid_state = sqlc.arg(grid_state)
 WHERE
-    id = ?;
+    id = sqlc.arg(id)
+    AND grid_state = sqlc.arg(old_grid_state)
+    AND game_failed = FALSE
+    AND game_won = FALSE;
 
 -- name: GetGamesInfoByUuids :one
+-- Abandoned games were neither won nor lost, they are left out.
 SELECT 
     COUNT(*) AS total_games,
     COUNT(*) FILTER (WHERE game_won = TRUE) AS won_games,
@@ -60,16 +158,19 @@ SELECT
 FROM 
     games
 WHERE 
-    uuid IN (sqlc.slice('uuids'));
+    uuid IN (sqlc.slice('uuids')) AND abandoned_at IS NULL;
 
 -- name: GetGamesInfo :one
+-- Abandoned games were neither won nor lost, they are left out.
 SELECT 
     COUNT(*) AS total_games,
     COUNT(*) FILTER (WHERE game_won = TRUE) AS won_games,
     COUNT(*) FILTER (WHERE game_failed = TRUE AND game_won = FALSE) AS lost_games,
     COUNT(*) FILTER (WHERE game_failed = FALSE AND game_won = FALSE) AS not_finished_games
 FROM 
-    games;
+    games
+WHERE
+    abandoned_at IS NULL;
 
 -- name: GetMovesByGameId :many
 SELECT
@@ -77,7 +178,9 @@ SELECT
 FROM
     moves
 WHERE
-    game_id = ?;
+    game_id = ?
+ORDER BY
+    id;
 
 -- name: GetGamesByMonthYearGroupedByDay :many
 SELECT 
@@ -98,4 +201,57 @@ ORDER BY grid_size;
 SELECT mines_amount, COUNT(*) AS mines_count
 FROM games
 GROUP BY mines_amount
-ORDER BY mines_amount;
\ No newline at end of file
+ORDER BY mines_amount;
+
+-- name: ListLegacyGridStates :many
+SELECT
+    id, grid_size, grid_state
+FROM
+    games
+WHERE
+    id > ? AND substr(grid_state, 1, 1) <> '~'
+ORDER BY
+    id
+LIMIT ?;
+
+-- name: ReplaceGameGridState :execrows
+UPDATE
+    games
+SET
+    grid_state = sqlc.arg(new_grid_state)
+WHERE
+    id = sqlc.arg(id) AND grid_state = sqlc.arg(old_grid_state);
+
+-- name: AbandonGameByUuid :one
+-- Ends the unfinished game without reading its grid, so that games with a corrupt grid can be abandoned too.
+-- Returns no rows for a game that is missing or already over.
+UPDATE
+    games
+SET
+    game_failed = TRUE,
+    abandoned_at = CURRENT_TIMESTAMP
+WHERE
+    uuid = ? AND game_failed = FALSE AND game_won = FALSE
+RETURNING *;
+
+-- name: DeleteGameById :execrows
+DELETE FROM
+    games
+WHERE
+    id = ?;
+
+-- name: AbandonUnfinishedGamesCreatedBefore :execrows
+UPDATE
+    games
+SET
+    game_failed = TRUE,
+    abandoned_at = CURRENT_TIMESTAMP
+WHERE
+    created_at < sqlc.arg(created_before) AND game_failed = FALSE AND game_won = FALSE;
+
+-- name: DeleteUnfinishedGamesCreatedBefore :execrows
+-- The moves of the deleted games are deleted with them by the foreign key.
+DELETE FROM
+    games
+WHERE
+    created_at < sqlc.arg(created_before) AND game_failed = FALSE AND game_won = FALSE;
@@ -17,13 +17,14 @@ function toggleFieldVisibility(fieldId, checkbox) {
 }
 
 function adjustMinesInputFieldRange() {
-    const gridSizeValue = document.getElementById(
-        "grid-size-input-field",
-    ).value;
+    const gridSizeInputField = document.getElementById("grid-size-input-field");
+    const gridSizeValue = gridSizeInputField.value;
+    // the limit configured on the server, rendered into the form
+    const maxMinesRatio = Number(gridSizeInputField.dataset.maxMinesRatio) || 0.8;
     const minesInputField = document.getElementById("mines-input-field");
 
     if (gridSizeValue) {
-        const maxMines = Math.floor(gridSizeValue ** 2 * 0.8);
+        const maxMines = Math.floor(gridSizeValue ** 2 * maxMinesRatio);
         minesInputField.min = 1;
         minesInputField.max = maxMines;
         minesInputField.placeholder = `Enter number of mines (max: ${maxMines})`;
@@ -37,6 +38,8 @@ function adjustMinesInputFieldRange() {
 window.addEventListener("load", () => {
     const gridSizeInputField = document.getElementById("grid-size-input-field");
 
-    const maxGridSizeBasedOnScreenSize = window.innerWidth < 768 ? 10 : 22;
-    gridSizeInputField.max = maxGridSizeBasedOnScreenSize;
+    // large boards are still allowed on bigger screens, the limit set in the form is kept there
+    if (window.innerWidth < 768) {
+        gridSizeInputField.max = 10;
+    }
 });
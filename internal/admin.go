@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"html/template"
+	"net/http"
+)
+
+// AdminConfig holds the credentials of the admin area, they are checked with HTTP basic auth.
+type AdminConfig struct {
+	Username string
+	Password string
+	// Insecure opens the admin area without credentials, for development. Nobody is an admin then,
+	// so it only shows redacted games. It has no effect once credentials are set.
+	Insecure bool
+}
+
+// Enabled tells whether admins can sign in. Without credentials the admin area is refused,
+// unless it is opened with Insecure.
+func (c AdminConfig) Enabled() bool {
+	return c.Username != "" && c.Password != ""
+}
+
+// valid compares the sent credentials in constant time, hashing them first so their length doesn't leak either.
+func (c AdminConfig) valid(username string, password string) bool {
+	sentUsername, sentPassword := sha256.Sum256([]byte(username)), sha256.Sum256([]byte(password))
+	expectedUsername, expectedPassword := sha256.Sum256([]byte(c.Username)), sha256.Sum256([]byte(c.Password))
+
+	usernameMatch := subtle.ConstantTimeCompare(sentUsername[:], expectedUsername[:])
+	passwordMatch := subtle.ConstantTimeCompare(sentPassword[:], expectedPassword[:])
+	return usernameMatch&passwordMatch == 1
+}
+
+const adminContextKey contextKey = "admin"
+
+// adminRealm is sent with the basic auth challenge, browsers show it in their sign-in prompt.
+const adminRealm = `Basic realm="Minesweeper admin", charset="UTF-8"`
+
+var (
+	errAdminUnauthorized = errors.New("missing or invalid admin credentials")
+	errAdminDisabled     = errors.New("admin area is disabled without credentials")
+)
+
+// AdminAuth guards the admin area. Once credentials are configured, requests without them are rejected
+// with 401 Unauthorized and a basic auth challenge, and the requests with them are marked as coming from
+// an admin, see IsAdmin. Without credentials every request is rejected with 403 Forbidden,
+// unless the config is Insecure.
+func AdminAuth(config AdminConfig, templates *template.Template) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !config.Enabled() {
+				if !config.Insecure {
+					writeError(w, r, templates, Forbidden("The admin area is disabled until an admin password is set.", errAdminDisabled))
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			username, password, ok := r.BasicAuth()
+			if !ok || !config.valid(username, password) {
+				w.Header().Set("WWW-Authenticate", adminRealm)
+				writeError(w, r, templates, Unauthorized("Sign in as an admin to see this page.", errAdminUnauthorized))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), adminContextKey, true)))
+		})
+	}
+}
+
+// IsAdmin tells whether the request was authenticated by AdminAuth.
+func IsAdmin(ctx context.Context) bool {
+	isAdmin, _ := ctx.Value(adminContextKey).(bool)
+	return isAdmin
+}
+
+// redactedUuidLength keeps enough of a uuid to tell games apart, but not enough to load them.
+const redactedUuidLength = 8
+
+// RedactUuid hides all but the start of a game uuid. Anybody who knows the whole uuid can play the game,
+// so they are only shown to admins.
+func RedactUuid(uuid string) string {
+	if len(uuid) <= redactedUuidLength {
+		return "…"
+	}
+	return uuid[:redactedUuidLength] + "…"
+}
@@ -0,0 +1,232 @@
+package internal
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"minesweeper/internal/db"
+	"minesweeper/internal/game"
+	"minesweeper/internal/models"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+var (
+	errNotAdmin        = errors.New("admin action without admin credentials")
+	errNoGamesSelected = errors.New("bulk action without games")
+)
+
+// GameStatus derives the status of a game the way the ListFilteredGames query does:
+// won, abandoned, lost or in_progress.
+func GameStatus(gameWon bool, gameFailed bool, abandonedAt sql.NullTime) string {
+	switch {
+	case gameWon:
+		return "won"
+	case abandonedAt.Valid:
+		return "abandoned"
+	case gameFailed:
+		return "lost"
+	default:
+		return "in_progress"
+	}
+}
+
+// gameDetailsURL is the admin page of the game with the given uuid.
+func gameDetailsURL(uuid string) string {
+	return "/games/" + url.PathEscape(uuid) + "/details"
+}
+
+// moveDetails is a move as shown on the admin page of a game.
+type moveDetails struct {
+	db.Move
+	Number        int
+	SinceStart    time.Duration
+	SincePrevious time.Duration
+}
+
+// requireAdmin rejects requests that are not from an admin. An Insecure admin area is open without credentials,
+// but the whole uuid and the board of a game are only for admins, see RedactUuid.
+func (h *Handler) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if IsAdmin(r.Context()) {
+		return true
+	}
+	h.error(w, r, Forbidden("Only admins can manage games.", errNotAdmin))
+	return false
+}
+
+// GameDetails handles GET /games/{uuid}/details. It shows an admin the whole board with the mines,
+// the moves and their timings, the session that started the game and its raw grid state.
+func (h *Handler) GameDetails(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	gameUuid := r.PathValue("uuid")
+	dbGame, err := h.Queries.GetGameByUuid(r.Context(), gameUuid)
+	if errors.Is(err, sql.ErrNoRows) {
+		h.error(w, r, fmt.Errorf("%w: %s", game.ErrGameNotFound, gameUuid))
+		return
+	}
+	if err != nil {
+		h.error(w, r, fmt.Errorf("failed to get game %s: %w", gameUuid, err))
+		return
+	}
+
+	moves, err := h.Queries.GetMovesByGameId(r.Context(), dbGame.Id)
+	if err != nil {
+		h.error(w, r, fmt.Errorf("failed to get moves of game %s: %w", gameUuid, err))
+		return
+	}
+
+	// a corrupted grid state is still shown raw, it is what an admin would want to look at
+	board, boardErr := models.FromDbGame(&dbGame)
+	var boardError string
+	if boardErr != nil {
+		boardError = boardErr.Error()
+	}
+
+	movesDetails := make([]moveDetails, len(moves))
+	previous := dbGame.CreatedAt
+	var duration time.Duration
+	for i, move := range moves {
+		movesDetails[i] = moveDetails{Move: move, Number: i + 1}
+		if move.CreateAt.Valid && dbGame.CreatedAt.Valid {
+			movesDetails[i].SinceStart = move.CreateAt.Time.Sub(dbGame.CreatedAt.Time).Round(time.Millisecond)
+			duration = movesDetails[i].SinceStart
+		}
+		if move.CreateAt.Valid && previous.Valid {
+			movesDetails[i].SincePrevious = move.CreateAt.Time.Sub(previous.Time).Round(time.Millisecond)
+		}
+		previous = move.CreateAt
+	}
+	if dbGame.AbandonedAt.Valid && dbGame.CreatedAt.Valid {
+		duration = dbGame.AbandonedAt.Time.Sub(dbGame.CreatedAt.Time).Round(time.Millisecond)
+	}
+
+	data := struct {
+		Game       db.Game
+		Status     string
+		Board      *models.Game
+		BoardError string
+		Moves      []moveDetails
+		Duration   time.Duration
+		CSRFToken  string
+	}{
+		Game:       dbGame,
+		Status:     GameStatus(dbGame.GameWon, dbGame.GameFailed, dbGame.AbandonedAt),
+		Board:      board,
+		BoardError: boardError,
+		Moves:      movesDetails,
+		Duration:   duration,
+		CSRFToken:  CSRFTokenFromContext(r.Context()),
+	}
+
+	if err := h.Templates.ExecuteTemplate(w, "game_details_page", data); err != nil {
+		h.error(w, r, fmt.Errorf("failed to render template: %w", err))
+		return
+	}
+}
+
+// AbandonGame handles POST /games/{uuid}/abandon, it ends an unfinished game.
+func (h *Handler) AbandonGame(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	gameUuid := r.PathValue("uuid")
+	if _, err := h.Games.Abandon(r.Context(), gameUuid); err != nil {
+		h.error(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, gameDetailsURL(gameUuid), http.StatusSeeOther)
+}
+
+// CloneGame handles POST /games/{uuid}/clone. The new game has the same board, nothing revealed, and is
+// added to the admin's session so it can be played right away.
+func (h *Handler) CloneGame(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	clonedGame, err := h.Games.Clone(r.Context(), r.PathValue("uuid"), SessionID(r, h.Store))
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+	if err := h.saveNewGameToSession(w, r, clonedGame); err != nil {
+		h.error(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, gameDetailsURL(clonedGame.Uuid), http.StatusSeeOther)
+}
+
+// DeleteGame handles POST /games/{uuid}/delete, it removes the game and its moves.
+func (h *Handler) DeleteGame(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	if err := h.Games.Delete(r.Context(), r.PathValue("uuid")); err != nil {
+		h.error(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/games", http.StatusSeeOther)
+}
+
+// BulkGames handles POST /games/bulk. It abandons or deletes the games selected on the list at once, at most
+// a page of them, and goes back to the list with the filters it was submitted from.
+func (h *Handler) BulkGames(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.error(w, r, BadRequest("The submitted form could not be read.", err))
+		return
+	}
+
+	uuids := r.PostForm["uuid"]
+	if len(uuids) == 0 {
+		h.error(w, r, Unprocessable("Select the games first.", errNoGamesSelected))
+		return
+	}
+	if maxGames := gamePageSizes[len(gamePageSizes)-1]; len(uuids) > maxGames {
+		h.error(w, r, Unprocessable(fmt.Sprintf("At most %d games can be changed at once.", maxGames), nil))
+		return
+	}
+
+	// the filters only lead back to the list, a broken one is no reason to fail the action
+	filter := DefaultGamesFilter()
+	if query, err := url.ParseQuery(r.PostForm.Get("filters")); err == nil {
+		if parsed, err := ParseGamesFilter(query); err == nil {
+			filter = parsed
+		}
+	}
+
+	action := r.PostForm.Get("action")
+	var (
+		changed int
+		err     error
+	)
+	switch action {
+	case "abandon":
+		changed, err = h.Games.AbandonAll(r.Context(), uuids)
+	case "delete":
+		changed, err = h.Games.DeleteAll(r.Context(), uuids)
+	default:
+		h.error(w, r, Unprocessable("The action must be abandon or delete.", fmt.Errorf("unknown bulk action %q", action)))
+		return
+	}
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Games changed in bulk", "action", action, "selected", len(uuids), "changed", changed)
+	http.Redirect(w, r, filter.url(), http.StatusSeeOther)
+}
@@ -0,0 +1,188 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"minesweeper/internal/game"
+	"minesweeper/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// newAdminTestHandler returns a handler backed by a real game service and database, with admin credentials.
+func newAdminTestHandler(t *testing.T) (*Handler, *game.Service) {
+	t.Helper()
+
+	database := newTestDatabase(t)
+	service := game.NewService(database.Repository(), database, nil)
+
+	h := newTestHandler(t, service)
+	h.Queries = database.Repository()
+	h.Admin = AdminConfig{Username: "admin", Password: "secret"}
+	return h, service
+}
+
+func adminRequest(method string, target string) *http.Request {
+	request := httptest.NewRequest(method, target, nil)
+	request.SetBasicAuth("admin", "secret")
+	return request
+}
+
+func TestGameDetails(t *testing.T) {
+	h, service := newAdminTestHandler(t)
+	ctx := context.Background()
+
+	created, err := service.Create(ctx, game.Settings{GridSize: 5, MinesAmount: 3, WinRule: models.WinRuleClassic}, "session-1")
+	if err != nil {
+		t.Fatalf("Failed to create game: %v", err)
+	}
+	if _, err := service.Act(ctx, created.Uuid, game.ActionFlagCell, 2, 3); err != nil {
+		t.Fatalf("Failed to act: %v", err)
+	}
+	dbGame, err := h.Queries.GetGameByUuid(ctx, created.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to get game: %v", err)
+	}
+
+	recorder := serve(h, adminRequest(http.MethodGet, gameDetailsURL(created.Uuid)))
+	body := recorder.Body.String()
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, but got %d: %s", recorder.Code, body)
+	}
+	for _, expected := range []string{created.Uuid, "session-1", "in_progress", dbGame.GridState, "flag", "2, 3", `action="/games/` + created.Uuid + `/abandon"`} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected the details to contain %q", expected)
+		}
+	}
+	if strings.Count(body, "💣") != 3 {
+		t.Errorf("Expected all 3 mines to be visible, but got %d", strings.Count(body, "💣"))
+	}
+
+	recorder = serve(h, adminRequest(http.MethodGet, gameDetailsURL("missing")))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing game, but got %d", recorder.Code)
+	}
+
+	// without credentials the admin area is open, but nobody may see the board
+	h.Admin = AdminConfig{Insecure: true}
+	recorder = serve(h, httptest.NewRequest(http.MethodGet, gameDetailsURL(created.Uuid), nil))
+	if recorder.Code != http.StatusForbidden || strings.Contains(recorder.Body.String(), dbGame.GridState) {
+		t.Errorf("Expected the details to be forbidden to non-admins, but got %d", recorder.Code)
+	}
+}
+
+func TestGameAdminActions(t *testing.T) {
+	h, service := newAdminTestHandler(t)
+	ctx := context.Background()
+
+	created, err := service.Create(ctx, game.Settings{GridSize: 5, MinesAmount: 3, WinRule: models.WinRuleClassic}, "")
+	if err != nil {
+		t.Fatalf("Failed to create game: %v", err)
+	}
+
+	recorder := serve(h, adminRequest(http.MethodPost, "/games/"+created.Uuid+"/abandon"))
+	if recorder.Code != http.StatusSeeOther || recorder.Header().Get("Location") != gameDetailsURL(created.Uuid) {
+		t.Errorf("Expected a redirect to the details, but got %d to %s", recorder.Code, recorder.Header().Get("Location"))
+	}
+	if recorder := serve(h, adminRequest(http.MethodGet, gameDetailsURL(created.Uuid))); !strings.Contains(recorder.Body.String(), "abandoned") {
+		t.Errorf("Expected the game to be shown as abandoned")
+	}
+	// abandoned games are failed too, but the list tells them from the lost ones
+	if body := serve(h, adminRequest(http.MethodGet, "/games")).Body.String(); !strings.Contains(body, `title="abandoned"`) || strings.Contains(body, `title="lost"`) {
+		t.Errorf("Expected the game to be listed as abandoned rather than lost")
+	}
+	if recorder := serve(h, adminRequest(http.MethodPost, "/games/"+created.Uuid+"/abandon")); recorder.Code != http.StatusConflict {
+		t.Errorf("Expected abandoning a finished game to conflict, but got %d", recorder.Code)
+	}
+
+	recorder = serve(h, adminRequest(http.MethodPost, "/games/"+created.Uuid+"/clone"))
+	location := recorder.Header().Get("Location")
+	if recorder.Code != http.StatusSeeOther || location == gameDetailsURL(created.Uuid) || !strings.HasSuffix(location, "/details") {
+		t.Fatalf("Expected a redirect to the clone, but got %d to %s", recorder.Code, location)
+	}
+	if len(recorder.Result().Cookies()) == 0 {
+		t.Errorf("Expected the clone to be saved in the admin's session")
+	}
+	cloneUuid := strings.TrimSuffix(strings.TrimPrefix(location, "/games/"), "/details")
+	if clone, err := service.Load(ctx, cloneUuid); err != nil || clone.GameFailed {
+		t.Errorf("Expected the clone to be playable, but got %+v (%v)", clone, err)
+	}
+
+	recorder = serve(h, adminRequest(http.MethodPost, "/games/"+created.Uuid+"/delete"))
+	if recorder.Code != http.StatusSeeOther || recorder.Header().Get("Location") != "/games" {
+		t.Errorf("Expected a redirect to the list, but got %d to %s", recorder.Code, recorder.Header().Get("Location"))
+	}
+	if recorder := serve(h, adminRequest(http.MethodGet, gameDetailsURL(created.Uuid))); recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected the deleted game to be gone, but got %d", recorder.Code)
+	}
+
+	h.Admin = AdminConfig{Insecure: true}
+	for _, action := range []string{"abandon", "clone", "delete"} {
+		recorder := serve(h, httptest.NewRequest(http.MethodPost, "/games/"+cloneUuid+"/"+action, nil))
+		if recorder.Code != http.StatusForbidden {
+			t.Errorf("Expected %s to be forbidden to non-admins, but got %d", action, recorder.Code)
+		}
+	}
+}
+
+func TestBulkGames(t *testing.T) {
+	h, service := newAdminTestHandler(t)
+	ctx := context.Background()
+
+	var uuids []string
+	for i := 0; i < 3; i++ {
+		created, err := service.Create(ctx, game.Settings{GridSize: 5, MinesAmount: 3, WinRule: models.WinRuleClassic}, "")
+		if err != nil {
+			t.Fatalf("Failed to create game: %v", err)
+		}
+		uuids = append(uuids, created.Uuid)
+	}
+
+	bulk := func(form url.Values) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/games/bulk", strings.NewReader(form.Encode()))
+		request.SetBasicAuth("admin", "secret")
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return serve(h, request)
+	}
+
+	recorder := bulk(url.Values{"action": {"abandon"}, "uuid": uuids[:2], "filters": {"status=in_progress&page_size=50"}})
+	if recorder.Code != http.StatusSeeOther || recorder.Header().Get("Location") != "/games?page_size=50&status=in_progress" {
+		t.Errorf("Expected a redirect back to the filtered list, but got %d to %s", recorder.Code, recorder.Header().Get("Location"))
+	}
+	for i, uuid := range uuids {
+		loaded, err := service.Load(ctx, uuid)
+		if err != nil || loaded.GameFailed != (i < 2) {
+			t.Errorf("Expected only the selected games to be abandoned, but game %d got %+v (%v)", i, loaded, err)
+		}
+	}
+
+	recorder = bulk(url.Values{"action": {"delete"}, "uuid": uuids[1:], "filters": {"page_size=1000"}})
+	if recorder.Code != http.StatusSeeOther || recorder.Header().Get("Location") != "/games" {
+		t.Errorf("Expected a redirect to the unfiltered list for broken filters, but got %d to %s", recorder.Code, recorder.Header().Get("Location"))
+	}
+	if _, err := service.Load(ctx, uuids[0]); err != nil {
+		t.Errorf("Expected the game that wasn't selected to be kept, but got %v", err)
+	}
+	for _, uuid := range uuids[1:] {
+		if _, err := service.Load(ctx, uuid); !errors.Is(err, game.ErrGameNotFound) {
+			t.Errorf("Expected game %s to be deleted, but got %v", uuid, err)
+		}
+	}
+
+	for name, form := range map[string]url.Values{
+		"No games":       {"action": {"delete"}},
+		"Unknown action": {"action": {"explode"}, "uuid": uuids[:1]},
+	} {
+		if recorder := bulk(form); recorder.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: expected status 422, but got %d", name, recorder.Code)
+		}
+	}
+
+	h.Admin = AdminConfig{Insecure: true}
+	if recorder := bulk(url.Values{"action": {"delete"}, "uuid": uuids[:1]}); recorder.Code != http.StatusForbidden {
+		t.Errorf("Expected bulk actions to be forbidden to non-admins, but got %d", recorder.Code)
+	}
+}
@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminAuth(t *testing.T) {
+	h := newTestHandler(t, newFakeGameService())
+
+	var sawAdmin bool
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sawAdmin = IsAdmin(r.Context())
+	})
+
+	testCases := []struct {
+		name           string
+		config         AdminConfig
+		username       string
+		password       string
+		expectedStatus int
+		expectedAdmin  bool
+	}{
+		{"No credentials configured", AdminConfig{Username: "admin"}, "", "", http.StatusForbidden, false},
+		{"Insecure without credentials", AdminConfig{Username: "admin", Insecure: true}, "", "", http.StatusOK, false},
+		{"Insecure with credentials configured", AdminConfig{Username: "admin", Password: "secret", Insecure: true}, "", "", http.StatusUnauthorized, false},
+		{"Missing credentials", AdminConfig{Username: "admin", Password: "secret"}, "", "", http.StatusUnauthorized, false},
+		{"Wrong password", AdminConfig{Username: "admin", Password: "secret"}, "admin", "guess", http.StatusUnauthorized, false},
+		{"Wrong username", AdminConfig{Username: "admin", Password: "secret"}, "root", "secret", http.StatusUnauthorized, false},
+		{"Valid credentials", AdminConfig{Username: "admin", Password: "secret"}, "admin", "secret", http.StatusOK, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sawAdmin = false
+			request := httptest.NewRequest(http.MethodGet, "/games", nil)
+			if tc.username != "" {
+				request.SetBasicAuth(tc.username, tc.password)
+			}
+			recorder := httptest.NewRecorder()
+
+			AdminAuth(tc.config, h.Templates)(next).ServeHTTP(recorder, request)
+
+			if recorder.Code != tc.expectedStatus {
+				t.Errorf("Expected status %d, but got %d", tc.expectedStatus, recorder.Code)
+			}
+			if sawAdmin != tc.expectedAdmin {
+				t.Errorf("Expected the request to be from an admin: %v, but got %v", tc.expectedAdmin, sawAdmin)
+			}
+			if tc.expectedStatus == http.StatusUnauthorized && recorder.Header().Get("WWW-Authenticate") != adminRealm {
+				t.Errorf("Expected a basic auth challenge, but got %q", recorder.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestAdminRoutesRequireCredentials(t *testing.T) {
+	h := newTestHandler(t, newFakeGameService())
+	h.Admin = AdminConfig{Username: "admin", Password: "secret"}
+
+	for _, path := range []string{"/games", "/charts", "/api/charts/bar/grid-size"} {
+		recorder := serve(h, httptest.NewRequest(http.MethodGet, path, nil))
+		if recorder.Code != http.StatusUnauthorized {
+			t.Errorf("Expected %s to require credentials, but got %d", path, recorder.Code)
+		}
+	}
+
+	recorder := serve(h, httptest.NewRequest(http.MethodGet, "/", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected the game to stay open to everybody, but got %d", recorder.Code)
+	}
+
+	// without a password the admin area is refused, unless it is opened on purpose
+	h.Admin = AdminConfig{Username: "admin"}
+	for _, path := range []string{"/games", "/charts", "/api/charts/bar/grid-size"} {
+		recorder := serve(h, httptest.NewRequest(http.MethodGet, path, nil))
+		if recorder.Code != http.StatusForbidden {
+			t.Errorf("Expected %s to be disabled without a password, but got %d", path, recorder.Code)
+		}
+	}
+}
+
+func TestRedactUuid(t *testing.T) {
+	testCases := map[string]string{
+		"0f8fad5b-d9cb-469f-a165-70867728950e": "0f8fad5b…",
+		"short":                                "…",
+	}
+
+	for uuid, expected := range testCases {
+		if redacted := RedactUuid(uuid); redacted != expected {
+			t.Errorf("Expected %s to be redacted to %s, but got %s", uuid, expected, redacted)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"html/template"
 	"minesweeper/internal/db"
+	"minesweeper/internal/storage"
 	"net/http"
 	"strconv"
 	"strings"
@@ -21,13 +22,18 @@ import (
 type ApiHandler struct {
 	Templates *template.Template
 	Store     *sessions.CookieStore
-	Queries   *db.Queries
+	Queries   storage.Repository
 }
 
-func NewApiHandler(templates *template.Template, store *sessions.CookieStore, queries *db.Queries) *ApiHandler {
+func NewApiHandler(templates *template.Template, store *sessions.CookieStore, queries storage.Repository) *ApiHandler {
 	return &ApiHandler{templates, store, queries}
 }
 
+// error responds with the status code and safe message of err, see writeError.
+func (h *ApiHandler) error(w http.ResponseWriter, r *http.Request, err error) {
+	writeError(w, r, h.Templates, err)
+}
+
 // renderToHtml renders a chart as a template.HTML value.
 //
 // The argument should be a go-echarts chart that implements the Renderer interface.
@@ -64,7 +70,7 @@ func (h *ApiHandler) PieWinsLossesIncompleteChart(w http.ResponseWriter, r *http
 	rawData, err := h.Queries.GetGamesInfo(r.Context())
 
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error fetching DB information: %v", err), http.StatusInternalServerError)
+		h.error(w, r, fmt.Errorf("error fetching DB information: %w", err))
 		return
 	}
 
@@ -92,7 +98,7 @@ func (h *ApiHandler) PieWinsLossesIncompleteChart(w http.ResponseWriter, r *http
 	htmlPieSnipper, err := renderToHtml(pie)
 
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error rendering chart: %v", err), http.StatusInternalServerError)
+		h.error(w, r, fmt.Errorf("error rendering chart: %w", err))
 		return
 	}
 
@@ -104,7 +110,7 @@ func (h *ApiHandler) GridSizeBar(w http.ResponseWriter, r *http.Request) {
 	rawData, err := h.Queries.GetGamesPlayedPerGridSize(r.Context())
 
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error fetching grid size data: %v", err), http.StatusInternalServerError)
+		h.error(w, r, fmt.Errorf("error fetching grid size data: %w", err))
 		return
 	}
 
@@ -153,7 +159,7 @@ func (h *ApiHandler) GridSizeBar(w http.ResponseWriter, r *http.Request) {
 	htmlBarSnippet, err := renderToHtml(bar)
 
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error rendering chart: %v", err), http.StatusInternalServerError)
+		h.error(w, r, fmt.Errorf("error rendering chart: %w", err))
 		return
 	}
 
@@ -164,7 +170,7 @@ func (h *ApiHandler) MinesAmountBarChart(w http.ResponseWriter, r *http.Request)
 	rawDbData, err := h.Queries.GetMinesPopularity(r.Context())
 
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error fetching mines popularity data: %v", err), http.StatusInternalServerError)
+		h.error(w, r, fmt.Errorf("error fetching mines popularity data: %w", err))
 		return
 	}
 
@@ -214,7 +220,7 @@ func (h *ApiHandler) MinesAmountBarChart(w http.ResponseWriter, r *http.Request)
 	htmlBarSnippet, err := renderToHtml(bar)
 
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error rendering chart: %v", err), http.StatusInternalServerError)
+		h.error(w, r, fmt.Errorf("error rendering chart: %w", err))
 		return
 	}
 
@@ -231,7 +237,7 @@ func (h *ApiHandler) PlayedGamesInMonthBarChart(w http.ResponseWriter, r *http.R
 
 	parsedDate, err := time.Parse("2006-01", pickedDate)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Invalid date format: %v", err), http.StatusBadRequest)
+		h.error(w, r, Unprocessable("The picked date must look like 2006-01.", err))
 		return
 	}
 
@@ -254,7 +260,7 @@ func (h *ApiHandler) PlayedGamesInMonthBarChart(w http.ResponseWriter, r *http.R
 		CreatedAt_2: endTime,
 	})
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error fetching games: %v", err), http.StatusInternalServerError)
+		h.error(w, r, fmt.Errorf("error fetching games: %w", err))
 		return
 	}
 
@@ -288,7 +294,7 @@ func (h *ApiHandler) PlayedGamesInMonthBarChart(w http.ResponseWriter, r *http.R
 
 	htmlBarSnippet, err := renderToHtml(bar)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error rendering chart: %v", err), http.StatusInternalServerError)
+		h.error(w, r, fmt.Errorf("error rendering chart: %w", err))
 		return
 	}
 
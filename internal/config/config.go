@@ -0,0 +1,351 @@
+// Package config loads the configuration of the server.
+//
+// Every option can be set, from the lowest to the highest precedence, by its default, a KEY=VALUE config
+// file (the same format as .env), an environment variable with the same KEY, or a command-line flag.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"minesweeper/internal"
+	"minesweeper/internal/game"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Config is the configuration of the server.
+type Config struct {
+	Port        string
+	DatabaseURL string
+	// LogFile is where the logs are written to, stdout and stderr write to the standard streams.
+	LogFile string
+	// LogLevel is the lowest level of the logged records.
+	LogLevel slog.Level
+	Session  internal.SessionConfig
+	TLS      TLSConfig
+	Server   internal.ServerConfig
+	// RateLimit limits the requests per client IP and session, see internal.RateLimit.
+	RateLimit internal.RateLimitConfig
+	// Admin holds the credentials of the admin area, it is disabled without them.
+	Admin internal.AdminConfig
+	Game  game.Limits
+	// Retention configures the job removing old unfinished games, it is off by default.
+	Retention game.Retention
+}
+
+// TLSConfig holds the certificate and key the server serves HTTPS with, HTTPS is off while both are empty.
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+}
+
+// Enabled tells whether the server serves HTTPS itself.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// Default returns the configuration used for anything that is not set.
+// It has no database URL nor session secret, those have to be set.
+func Default() *Config {
+	return &Config{
+		Port:     "8080",
+		LogFile:  "minesweeper.log",
+		LogLevel: slog.LevelInfo,
+		Session: internal.SessionConfig{
+			SameSite: http.SameSiteLaxMode,
+			MaxAge:   time.Hour,
+		},
+		Server:    internal.DefaultServerConfig(),
+		RateLimit: internal.DefaultRateLimitConfig(),
+		Admin:     internal.AdminConfig{Username: "admin"},
+		Game:      game.DefaultLimits(),
+		Retention: game.DefaultRetention(),
+	}
+}
+
+// option is a single setting, key names both its environment variable and its config file entry.
+type option struct {
+	key   string
+	flag  string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+const (
+	sessionSecureKey         = "SESSION_SECURE"
+	gameMaxRandomGridSizeKey = "GAME_MAX_RANDOM_GRID_SIZE"
+)
+
+func options() []option {
+	return []option{
+		{"APP_PORT", "port", "port the server listens on (default 8080)", func(c *Config, value string) error {
+			c.Port = value
+			return nil
+		}},
+		{"DATABASE_URL", "database-url", "SQLite file path or postgres:// URL of the database", func(c *Config, value string) error {
+			c.DatabaseURL = value
+			return nil
+		}},
+		{"LOG_FILE", "log-file", "file the logs are appended to, or stdout or stderr (default minesweeper.log)", func(c *Config, value string) error {
+			c.LogFile = value
+			return nil
+		}},
+		{"LOG_LEVEL", "log-level", "lowest level logged: debug, info, warn or error (default info)", func(c *Config, value string) error {
+			return c.LogLevel.UnmarshalText([]byte(value))
+		}},
+		{"SESSION_SECRET", "session-secret", "comma separated secrets signing the session cookie, the first one signs new cookies", func(c *Config, value string) error {
+			c.Session.Secrets = strings.Split(value, ",")
+			for i, secret := range c.Session.Secrets {
+				c.Session.Secrets[i] = strings.TrimSpace(secret)
+			}
+			return nil
+		}},
+		{sessionSecureKey, "session-secure", "only send the session cookie over HTTPS (default true when TLS is enabled)", func(c *Config, value string) (err error) {
+			c.Session.Secure, err = strconv.ParseBool(value)
+			return err
+		}},
+		{"SESSION_SAMESITE", "session-samesite", "SameSite mode of the session cookie: lax, strict or none (default lax)", func(c *Config, value string) (err error) {
+			c.Session.SameSite, err = internal.ParseSameSite(value)
+			return err
+		}},
+		{"SESSION_DOMAIN", "session-domain", "domain of the session cookie (default the host only)", func(c *Config, value string) error {
+			c.Session.Domain = value
+			return nil
+		}},
+		{"SESSION_MAX_AGE", "session-max-age", "lifetime of the session (default 1h)", func(c *Config, value string) (err error) {
+			c.Session.MaxAge, err = time.ParseDuration(value)
+			return err
+		}},
+		{"TLS_CERT_FILE", "tls-cert-file", "certificate to serve HTTPS with", func(c *Config, value string) error {
+			c.TLS.CertFile = value
+			return nil
+		}},
+		{"TLS_KEY_FILE", "tls-key-file", "private key of the certificate", func(c *Config, value string) error {
+			c.TLS.KeyFile = value
+			return nil
+		}},
+		{"SERVER_READ_HEADER_TIMEOUT", "server-read-header-timeout", "time to read the headers of a request (default 5s)", func(c *Config, value string) (err error) {
+			c.Server.ReadHeaderTimeout, err = time.ParseDuration(value)
+			return err
+		}},
+		{"SERVER_READ_TIMEOUT", "server-read-timeout", "time to read a whole request (default 15s)", func(c *Config, value string) (err error) {
+			c.Server.ReadTimeout, err = time.ParseDuration(value)
+			return err
+		}},
+		{"SERVER_WRITE_TIMEOUT", "server-write-timeout", "time to write a response (default 30s)", func(c *Config, value string) (err error) {
+			c.Server.WriteTimeout, err = time.ParseDuration(value)
+			return err
+		}},
+		{"SERVER_IDLE_TIMEOUT", "server-idle-timeout", "time an idle keep-alive connection is kept open (default 2m)", func(c *Config, value string) (err error) {
+			c.Server.IdleTimeout, err = time.ParseDuration(value)
+			return err
+		}},
+		{"SHUTDOWN_TIMEOUT", "shutdown-timeout", "time in-flight requests get to finish on SIGTERM or SIGINT (default 15s)", func(c *Config, value string) (err error) {
+			c.Server.ShutdownTimeout, err = time.ParseDuration(value)
+			return err
+		}},
+		{"RATE_LIMITS", "rate-limits", "limits of routes replacing their defaults, like 'POST /games=ip:30/m session:10/m; *=ip:600/m'", func(c *Config, value string) error {
+			routes, err := internal.ParseRouteLimits(value)
+			if err != nil {
+				return err
+			}
+			for route, limit := range routes {
+				c.RateLimit.Routes[route] = limit
+			}
+			return nil
+		}},
+		{"RATE_LIMIT_TRUST_FORWARDED_FOR", "rate-limit-trust-forwarded-for", "take the client IP from X-Forwarded-For, only behind a proxy setting it (default false)", func(c *Config, value string) (err error) {
+			c.RateLimit.TrustForwardedFor, err = strconv.ParseBool(value)
+			return err
+		}},
+		{"ADMIN_USERNAME", "admin-username", "username of the admin area (default admin)", func(c *Config, value string) error {
+			c.Admin.Username = value
+			return nil
+		}},
+		{"ADMIN_PASSWORD", "admin-password", "password of the admin area, without it the admin area is disabled", func(c *Config, value string) error {
+			c.Admin.Password = value
+			return nil
+		}},
+		{"ADMIN_INSECURE", "admin-insecure", "open the admin area without a password, only showing redacted games, for development (default false)", func(c *Config, value string) (err error) {
+			c.Admin.Insecure, err = strconv.ParseBool(value)
+			return err
+		}},
+		{"GAME_MIN_GRID_SIZE", "game-min-grid-size", fmt.Sprintf("smallest grid size of a new game (default %d)", game.MinGridSize), func(c *Config, value string) (err error) {
+			c.Game.MinGridSize, err = strconv.Atoi(value)
+			return err
+		}},
+		{"GAME_MAX_GRID_SIZE", "game-max-grid-size", fmt.Sprintf("largest grid size of a new game (default %d)", game.MaxGridSize), func(c *Config, value string) (err error) {
+			c.Game.MaxGridSize, err = strconv.Atoi(value)
+			return err
+		}},
+		{gameMaxRandomGridSizeKey, "game-max-random-grid-size", fmt.Sprintf("largest randomly picked grid size (default %d, at most the max grid size)", game.MaxRandomGridSize), func(c *Config, value string) (err error) {
+			c.Game.MaxRandomGridSize, err = strconv.Atoi(value)
+			return err
+		}},
+		{"GAME_MIN_MINES_RATIO", "game-min-mines-ratio", fmt.Sprintf("smallest share of mines picked for random mines (default %v)", game.MinMinesRatio), func(c *Config, value string) (err error) {
+			c.Game.MinMinesRatio, err = strconv.ParseFloat(value, 64)
+			return err
+		}},
+		{"GAME_MAX_MINES_RATIO", "game-max-mines-ratio", fmt.Sprintf("largest share of cells that may be mines (default %v)", game.MaxMinesRatio), func(c *Config, value string) (err error) {
+			c.Game.MaxMinesRatio, err = strconv.ParseFloat(value, 64)
+			return err
+		}},
+		{"GAME_MAX_UNFINISHED_GAMES", "game-max-unfinished-games", fmt.Sprintf("unfinished games a session may have, 0 is no cap (default %d)", game.MaxUnfinishedGames), func(c *Config, value string) (err error) {
+			c.Game.MaxUnfinishedGames, err = strconv.Atoi(value)
+			return err
+		}},
+		{"GAME_RETENTION_MAX_AGE", "game-retention-max-age", "age after which unfinished games are purged, like 720h, 0 keeps them forever (default 0)", func(c *Config, value string) (err error) {
+			c.Retention.MaxAge, err = time.ParseDuration(value)
+			return err
+		}},
+		{"GAME_RETENTION_INTERVAL", "game-retention-interval", fmt.Sprintf("time between two purges of unfinished games (default %v)", game.RetentionInterval), func(c *Config, value string) (err error) {
+			c.Retention.Interval, err = time.ParseDuration(value)
+			return err
+		}},
+		{"GAME_RETENTION_ACTION", "game-retention-action", "what happens to old unfinished games: delete them with their moves, or archive them as abandoned (default delete)", func(c *Config, value string) (err error) {
+			c.Retention.Archive, err = game.ParseRetentionAction(value)
+			return err
+		}},
+	}
+}
+
+// Load registers the configuration flags on fs, parses args and builds the configuration from the flags,
+// the environment read through lookupEnv and the config file named by -config or CONFIG_FILE.
+// The returned configuration is validated.
+func Load(fs *flag.FlagSet, args []string, lookupEnv func(key string) (string, bool)) (*Config, error) {
+	configFile := fs.String("config", "", "KEY=VALUE file with the configuration, overridden by the environment and flags")
+
+	options := options()
+	flagValues := make([]*string, len(options))
+	for i, o := range options {
+		flagValues[i] = fs.String(o.flag, "", o.usage+" [$"+o.key+"]")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	setFlags := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+	})
+
+	// empty environment variables count as unset, like they did with os.Getenv
+	env := func(key string) (string, bool) {
+		value, ok := lookupEnv(key)
+		return value, ok && value != ""
+	}
+
+	fileValues := map[string]string{}
+	configPath, ok := env("CONFIG_FILE")
+	if setFlags["config"] || !ok {
+		configPath = *configFile
+	}
+	if configPath != "" {
+		var err error
+		if fileValues, err = godotenv.Read(configPath); err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+	}
+
+	config := Default()
+	applied := make(map[string]bool)
+
+	for i, o := range options {
+		value, ok := *flagValues[i], setFlags[o.flag]
+		if !ok {
+			value, ok = env(o.key)
+		}
+		if !ok {
+			value, ok = fileValues[o.key]
+			ok = ok && value != ""
+		}
+		if !ok {
+			continue
+		}
+
+		if err := o.set(config, value); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", o.key, err)
+		}
+		applied[o.key] = true
+	}
+
+	if !applied[sessionSecureKey] {
+		config.Session.Secure = config.TLS.Enabled()
+	}
+	// lowering the max grid size should not also require lowering the random one
+	if !applied[gameMaxRandomGridSizeKey] {
+		config.Game.MaxRandomGridSize = min(config.Game.MaxRandomGridSize, config.Game.MaxGridSize)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Validate reports every invalid or missing setting.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("APP_PORT must be a port number, but got %q", c.Port))
+	}
+	if c.DatabaseURL == "" {
+		errs = append(errs, errors.New("DATABASE_URL is not set"))
+	}
+	if c.LogFile == "" {
+		errs = append(errs, errors.New("LOG_FILE must not be empty"))
+	}
+
+	if len(c.Session.Secrets) == 0 {
+		errs = append(errs, errors.New("SESSION_SECRET is not set, the application cannot start without it"))
+	}
+	for _, secret := range c.Session.Secrets {
+		if secret == "" {
+			errs = append(errs, errors.New("SESSION_SECRET must not contain empty secrets"))
+			break
+		}
+	}
+	if c.Session.MaxAge < time.Second {
+		errs = append(errs, fmt.Errorf("SESSION_MAX_AGE must be at least a second, but got %v", c.Session.MaxAge))
+	}
+	if c.Session.SameSite == http.SameSiteNoneMode && !c.Session.Secure {
+		errs = append(errs, errors.New("SESSION_SAMESITE=none requires SESSION_SECURE"))
+	}
+
+	if c.TLS.Enabled() && (c.TLS.CertFile == "" || c.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("TLS needs both TLS_CERT_FILE and TLS_KEY_FILE to be set"))
+	}
+
+	serverTimeouts := []struct {
+		key     string
+		timeout time.Duration
+	}{
+		{"SERVER_READ_HEADER_TIMEOUT", c.Server.ReadHeaderTimeout},
+		{"SERVER_READ_TIMEOUT", c.Server.ReadTimeout},
+		{"SERVER_WRITE_TIMEOUT", c.Server.WriteTimeout},
+		{"SERVER_IDLE_TIMEOUT", c.Server.IdleTimeout},
+		{"SHUTDOWN_TIMEOUT", c.Server.ShutdownTimeout},
+	}
+	for _, serverTimeout := range serverTimeouts {
+		if serverTimeout.timeout <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive, but got %v", serverTimeout.key, serverTimeout.timeout))
+		}
+	}
+
+	if err := c.Game.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("invalid game limits: %w", err))
+	}
+	if err := c.Retention.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("invalid game retention: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,209 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"log/slog"
+	"minesweeper/internal"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func load(t *testing.T, args []string, env map[string]string) (*Config, error) {
+	t.Helper()
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	return Load(fs, args, func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	})
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := load(t, nil, map[string]string{"DATABASE_URL": "db/minesweeper.db", "SESSION_SECRET": "secret"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := Default()
+	if cfg.Port != expected.Port || cfg.LogFile != expected.LogFile || cfg.LogLevel != slog.LevelInfo || cfg.Server != expected.Server || cfg.Game != expected.Game {
+		t.Errorf("Expected the defaults %+v, but got %+v", expected, cfg)
+	}
+	if cfg.Session.MaxAge != time.Hour || cfg.Session.SameSite != http.SameSiteLaxMode || cfg.Session.Secure {
+		t.Errorf("Expected the default session settings, but got %+v", cfg.Session)
+	}
+	if cfg.TLS.Enabled() {
+		t.Errorf("Expected TLS to be disabled by default")
+	}
+	if cfg.Retention.Enabled() || cfg.Retention != expected.Retention {
+		t.Errorf("Expected the retention job to be off by default, but got %+v", cfg.Retention)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "minesweeper.conf")
+	fileContent := strings.Join([]string{
+		"DATABASE_URL=file.db",
+		"SESSION_SECRET=file-secret",
+		"APP_PORT=7000",
+		"LOG_FILE=stderr",
+		"GAME_MAX_GRID_SIZE=64",
+	}, "\n")
+	if err := os.WriteFile(configFile, []byte(fileContent), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	env := map[string]string{
+		"CONFIG_FILE":    configFile,
+		"SESSION_SECRET": "new-secret, old-secret",
+		"APP_PORT":       "7001",
+		"LOG_FILE":       "",
+		"LOG_LEVEL":      "debug",
+	}
+	cfg, err := load(t, []string{"-port", "7002", "-session-max-age", "30m"}, env)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if cfg.DatabaseURL != "file.db" {
+		t.Errorf("Expected the database URL from the file, but got %s", cfg.DatabaseURL)
+	}
+	if cfg.Game.MaxGridSize != 64 {
+		t.Errorf("Expected the max grid size from the file, but got %d", cfg.Game.MaxGridSize)
+	}
+	if len(cfg.Session.Secrets) != 2 || cfg.Session.Secrets[0] != "new-secret" || cfg.Session.Secrets[1] != "old-secret" {
+		t.Errorf("Expected the secrets from the environment, but got %q", cfg.Session.Secrets)
+	}
+	if cfg.LogFile != "stderr" {
+		t.Errorf("Expected an empty environment variable to leave the file value, but got %s", cfg.LogFile)
+	}
+	if cfg.LogLevel != slog.LevelDebug {
+		t.Errorf("Expected the log level from the environment, but got %v", cfg.LogLevel)
+	}
+	if cfg.Port != "7002" {
+		t.Errorf("Expected the port from the flag, but got %s", cfg.Port)
+	}
+	if cfg.Session.MaxAge != 30*time.Minute {
+		t.Errorf("Expected the session max age from the flag, but got %v", cfg.Session.MaxAge)
+	}
+}
+
+func TestLoadClampsRandomGridSize(t *testing.T) {
+	cfg, err := load(t, []string{"-game-max-grid-size", "10"}, map[string]string{"DATABASE_URL": "db/minesweeper.db", "SESSION_SECRET": "secret"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.Game.MaxRandomGridSize != 10 {
+		t.Errorf("Expected the random grid size to be capped by the max grid size, but got %d", cfg.Game.MaxRandomGridSize)
+	}
+}
+
+func TestLoadRateLimits(t *testing.T) {
+	env := map[string]string{
+		"DATABASE_URL":   "db/minesweeper.db",
+		"SESSION_SECRET": "secret",
+		"RATE_LIMITS":    "POST /games=ip:5/m; GET /games=session:2/10s",
+	}
+
+	cfg, err := load(t, nil, env)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	defaults := internal.DefaultRateLimitConfig()
+	expected := map[string]internal.RouteLimit{
+		"POST /games":              {PerIP: internal.Rate{Requests: 5, Period: time.Minute}},
+		"GET /games":               {PerSession: internal.Rate{Requests: 2, Period: 10 * time.Second}},
+		"POST /games/{uuid}/moves": defaults.Routes["POST /games/{uuid}/moves"],
+		internal.DefaultRoute:      defaults.Routes[internal.DefaultRoute],
+	}
+	for route, limit := range expected {
+		if cfg.RateLimit.Routes[route] != limit {
+			t.Errorf("Expected the limits of %s to be %+v, but got %+v", route, limit, cfg.RateLimit.Routes[route])
+		}
+	}
+}
+
+func TestLoadRetention(t *testing.T) {
+	env := map[string]string{
+		"DATABASE_URL":           "db/minesweeper.db",
+		"SESSION_SECRET":         "secret",
+		"GAME_RETENTION_MAX_AGE": "720h",
+		"GAME_RETENTION_ACTION":  "archive",
+	}
+
+	cfg, err := load(t, []string{"-game-retention-interval", "10m"}, env)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !cfg.Retention.Enabled() || cfg.Retention.MaxAge != 720*time.Hour || cfg.Retention.Interval != 10*time.Minute || !cfg.Retention.Archive {
+		t.Errorf("Expected the configured retention job, but got %+v", cfg.Retention)
+	}
+}
+
+func TestLoadSecureWithTLS(t *testing.T) {
+	env := map[string]string{
+		"DATABASE_URL":   "db/minesweeper.db",
+		"SESSION_SECRET": "secret",
+		"TLS_CERT_FILE":  "server.crt",
+		"TLS_KEY_FILE":   "server.key",
+	}
+
+	cfg, err := load(t, nil, env)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !cfg.TLS.Enabled() || !cfg.Session.Secure {
+		t.Errorf("Expected secure cookies with TLS enabled, but got %+v", cfg.Session)
+	}
+
+	cfg, err = load(t, []string{"-session-secure=false"}, env)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.Session.Secure {
+		t.Errorf("Expected SESSION_SECURE to override the TLS default")
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	valid := map[string]string{"DATABASE_URL": "db/minesweeper.db", "SESSION_SECRET": "secret"}
+
+	testCases := []struct {
+		name        string
+		args        []string
+		env         map[string]string
+		expectedErr string
+	}{
+		{"Missing database URL", nil, map[string]string{"SESSION_SECRET": "secret"}, "DATABASE_URL is not set"},
+		{"Missing session secret", nil, map[string]string{"DATABASE_URL": "db/minesweeper.db"}, "SESSION_SECRET is not set"},
+		{"Invalid port", []string{"-port", "http"}, valid, "APP_PORT must be a port number"},
+		{"Invalid duration", []string{"-session-max-age", "forever"}, valid, "invalid SESSION_MAX_AGE"},
+		{"Invalid log level", []string{"-log-level", "verbose"}, valid, "invalid LOG_LEVEL"},
+		{"Invalid rate limit", []string{"-rate-limits", "POST /games=ip:many/m"}, valid, "invalid RATE_LIMITS"},
+		{"Invalid SameSite", []string{"-session-samesite", "sometimes"}, valid, "invalid SESSION_SAMESITE"},
+		{"SameSite none without secure", []string{"-session-samesite", "none"}, valid, "requires SESSION_SECURE"},
+		{"Certificate without key", []string{"-tls-cert-file", "server.crt"}, valid, "TLS needs both"},
+		{"Zero timeout", []string{"-server-write-timeout", "0s"}, valid, "SERVER_WRITE_TIMEOUT must be positive"},
+		{"Invalid game limits", []string{"-game-max-grid-size", "1"}, valid, "invalid game limits"},
+		{"Invalid retention action", []string{"-game-retention-action", "keep"}, valid, "invalid GAME_RETENTION_ACTION"},
+		{"Zero retention interval", []string{"-game-retention-interval", "0s"}, valid, "invalid game retention"},
+		{"Missing config file", []string{"-config", "does-not-exist.conf"}, valid, "failed to read config file"},
+		{"Unknown flag", []string{"-grid-size", "10"}, valid, "flag provided but not defined"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := load(t, tc.args, tc.env)
+			if err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+				t.Errorf("Expected an error containing '%s', but got %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+
+	"github.com/gorilla/sessions"
+)
+
+const csrfTokenContextKey contextKey = "csrf_token"
+
+// CSRFHeader carries the CSRF token of HTMX requests, forms without JavaScript send the csrfFormField instead.
+const CSRFHeader = "X-CSRF-Token"
+
+const csrfFormField = "csrf_token"
+
+// csrfSessionKey holds the token in the session, it is compared with the one sent along a request.
+const csrfSessionKey = "csrf_token"
+
+var errInvalidCSRFToken = errors.New("missing or invalid CSRF token")
+
+// CSRF protects the requests that change state with a token kept in the session (synchronizer token pattern).
+//
+// Safe methods get a token and an ID (see SessionID) assigned to their session, the token is available
+// through CSRFTokenFromContext so the pages can send it back. Any other method is rejected with 403 Forbidden unless it carries the same token.
+func CSRF(store *sessions.CookieStore, templates *template.Template) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// a cookie that can't be decoded still gives a new, empty session
+			session, _ := store.Get(r, sessionName)
+			token, _ := session.Values[csrfSessionKey].(string)
+
+			if isSafeMethod(r.Method) {
+				// the first page of a player starts the session, with its ID and token
+				saveSession := ensureSessionID(session)
+				if token == "" {
+					token = newRandomToken(32)
+					session.Values[csrfSessionKey] = token
+					saveSession = true
+				}
+				if saveSession {
+					if err := session.Save(r, w); err != nil {
+						slog.ErrorContext(r.Context(), "Failed to save the new session", "error", err)
+					}
+				}
+
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), csrfTokenContextKey, token)))
+				return
+			}
+
+			sentToken := r.Header.Get(CSRFHeader)
+			if sentToken == "" {
+				sentToken = r.PostFormValue(csrfFormField)
+			}
+
+			if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(sentToken)) != 1 {
+				writeError(w, r, templates, Forbidden("Your session has expired, please reload the page.", errInvalidCSRFToken))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), csrfTokenContextKey, token)))
+		})
+	}
+}
+
+// CSRFTokenFromContext returns the token of the session assigned by CSRF, or an empty string outside of it.
+func CSRFTokenFromContext(ctx context.Context) string {
+	token, _ := ctx.Value(csrfTokenContextKey).(string)
+	return token
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
+
+// newRandomToken returns size random bytes encoded as hex, for the CSRF tokens and session IDs.
+func newRandomToken(size int) string {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		// without randomness there is no token worth handing out
+		panic(fmt.Sprintf("failed to generate random token: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
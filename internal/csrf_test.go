@@ -0,0 +1,136 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var csrfMetaTag = regexp.MustCompile(`<meta name="csrf-token" content="([0-9a-f]+)"`)
+
+// serveWithCSRF sends the request through the application routes behind the CSRF middleware.
+func serveWithCSRF(h *Handler, request *http.Request) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	routes := Routes(h, NewApiHandler(h.Templates, h.Store, h.Queries))
+	CSRF(h.Store, h.Templates)(routes).ServeHTTP(recorder, request)
+	return recorder
+}
+
+// csrfSession opens the home page and returns the session cookie and the token rendered into the page.
+func csrfSession(t *testing.T, h *Handler) (*http.Cookie, string) {
+	t.Helper()
+
+	recorder := serveWithCSRF(h, httptest.NewRequest(http.MethodGet, "/", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, but got %d", http.StatusOK, recorder.Code)
+	}
+
+	match := csrfMetaTag.FindStringSubmatch(recorder.Body.String())
+	if match == nil {
+		t.Fatalf("Expected the home page to contain the CSRF token")
+	}
+	if !strings.Contains(recorder.Body.String(), `name="csrf_token" value="`+match[1]+`"`) {
+		t.Errorf("Expected the settings form to contain the CSRF token")
+	}
+
+	cookies := recorder.Result().Cookies()
+	if len(cookies) == 0 {
+		t.Fatalf("Expected the CSRF token to be saved in the session cookie")
+	}
+
+	return cookies[0], match[1]
+}
+
+func TestCSRF(t *testing.T) {
+	testCases := []struct {
+		name           string
+		withSession    bool
+		headerToken    string
+		formToken      string
+		expectedStatus int
+	}{
+		{"Token in header", true, "valid", "", http.StatusOK},
+		{"Token in form", true, "", "valid", http.StatusOK},
+		{"Missing token", true, "", "", http.StatusForbidden},
+		{"Wrong token", true, "0123abcd", "", http.StatusForbidden},
+		{"Token without session", false, "valid", "", http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			games := newFakeGameService()
+			h := newTestHandler(t, games)
+			cookie, token := csrfSession(t, h)
+
+			body := url.Values{"grid-size": {"6"}, "mines-amount": {"5"}}
+			if tc.formToken != "" {
+				body.Set(csrfFormField, strings.ReplaceAll(tc.formToken, "valid", token))
+			}
+
+			request := httptest.NewRequest(http.MethodPost, "/games", strings.NewReader(body.Encode()))
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			request.Header.Set("HX-Request", "true")
+			if tc.headerToken != "" {
+				request.Header.Set(CSRFHeader, strings.ReplaceAll(tc.headerToken, "valid", token))
+			}
+			if tc.withSession {
+				request.AddCookie(cookie)
+			}
+
+			recorder := serveWithCSRF(h, request)
+
+			if recorder.Code != tc.expectedStatus {
+				t.Errorf("Expected status %d, but got %d", tc.expectedStatus, recorder.Code)
+			}
+
+			expectedGames := 0
+			if tc.expectedStatus == http.StatusOK {
+				expectedGames = 1
+			}
+			if len(games.created) != expectedGames {
+				t.Errorf("Expected %d created games, but got %d", expectedGames, len(games.created))
+			}
+		})
+	}
+}
+
+func TestCSRFKeepsToken(t *testing.T) {
+	h := newTestHandler(t, newFakeGameService())
+	cookie, token := csrfSession(t, h)
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.AddCookie(cookie)
+	recorder := serveWithCSRF(h, request)
+
+	if len(recorder.Result().Cookies()) != 0 {
+		t.Errorf("Expected the session not to be saved again")
+	}
+	if match := csrfMetaTag.FindStringSubmatch(recorder.Body.String()); match == nil || match[1] != token {
+		t.Errorf("Expected the token of the session to be rendered again")
+	}
+}
+
+func TestCSRFAssignsSessionID(t *testing.T) {
+	h := newTestHandler(t, newFakeGameService())
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	if sessionID := SessionID(request, h.Store); sessionID != "" {
+		t.Errorf("Expected no session ID without a session, but got %s", sessionID)
+	}
+
+	firstCookie, _ := csrfSession(t, h)
+	secondCookie, _ := csrfSession(t, h)
+
+	firstRequest := httptest.NewRequest(http.MethodGet, "/", nil)
+	firstRequest.AddCookie(firstCookie)
+	secondRequest := httptest.NewRequest(http.MethodGet, "/", nil)
+	secondRequest.AddCookie(secondCookie)
+
+	firstID, secondID := SessionID(firstRequest, h.Store), SessionID(secondRequest, h.Store)
+	if firstID == "" || firstID == secondID {
+		t.Errorf("Expected every session to get its own ID, but got %q and %q", firstID, secondID)
+	}
+}
@@ -17,6 +17,9 @@ type Game struct {
 	GameWon     bool
 	GridState   string
 	CreatedAt   sql.NullTime
+	WinRule     string
+	SessionId   sql.NullString
+	AbandonedAt sql.NullTime
 }
 
 type Move struct {
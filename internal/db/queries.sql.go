@@ -11,21 +11,123 @@ import (
 	"strings"
 )
 
+const abandonGameByUuid = `-- name: AbandonGameByUuid :one
+UPDATE
+    games
+SET
+    game_failed = TRUE,
+    abandoned_at = CURRENT_TIMESTAMP
+WHERE
+    uuid = ? AND game_failed = FALSE AND game_won = FALSE
+RETURNING id, uuid, grid_size, mines_amount, game_failed, game_won, grid_state, created_at, win_rule, session_id, abandoned_at
+`
+
+// Ends the unfinished game without reading its grid, so that games with a corrupt grid can be abandoned too.
+// Returns no rows for a game that is missing or already over.
+func (q *Queries) AbandonGameByUuid(ctx context.Context, uuid string) (Game, error) {
+	row := q.db.QueryRowContext(ctx, abandonGameByUuid, uuid)
+	var i Game
+	err := row.Scan(
+		&i.Id,
+		&i.Uuid,
+		&i.GridSize,
+		&i.MinesAmount,
+		&i.GameFailed,
+		&i.GameWon,
+		&i.GridState,
+		&i.CreatedAt,
+		&i.WinRule,
+		&i.SessionId,
+		&i.AbandonedAt,
+	)
+	return i, err
+}
+
+const abandonUnfinishedGamesCreatedBefore = `-- name: AbandonUnfinishedGamesCreatedBefore :execrows
+UPDATE
+    games
+SET
+    game_failed = TRUE,
+    abandoned_at = CURRENT_TIMESTAMP
+WHERE
+    created_at < ?1 AND game_failed = FALSE AND game_won = FALSE
+`
+
+func (q *Queries) AbandonUnfinishedGamesCreatedBefore(ctx context.Context, createdBefore sql.NullTime) (int64, error) {
+	result, err := q.db.ExecContext(ctx, abandonUnfinishedGamesCreatedBefore, createdBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const countFilteredGames = `-- name: CountFilteredGames :one
+SELECT
+    COUNT(games.id) AS count
+FROM
+    games
+WHERE
+    (CAST(?1 AS TEXT) IS NULL
+        OR CAST(?1 AS TEXT) = CASE WHEN games.game_won THEN 'won' WHEN games.abandoned_at IS NOT NULL THEN 'abandoned' WHEN games.game_failed THEN 'lost' ELSE 'in_progress' END)
+    AND (games.grid_size >= ?2 OR ?2 IS NULL)
+    AND (games.grid_size <= ?3 OR ?3 IS NULL)
+    AND (games.mines_amount >= ?4 OR ?4 IS NULL)
+    AND (games.mines_amount <= ?5 OR ?5 IS NULL)
+    AND (games.created_at >= CAST(?6 AS TEXT) OR ?6 IS NULL)
+    AND (games.created_at < CAST(?7 AS TEXT) OR ?7 IS NULL)
+    AND (games.uuid LIKE CAST(?8 AS TEXT) || '%' OR ?8 IS NULL)
+`
+
+type CountFilteredGamesParams struct {
+	Status        sql.NullString
+	MinGridSize   sql.NullInt64
+	MaxGridSize   sql.NullInt64
+	MinMines      sql.NullInt64
+	MaxMines      sql.NullInt64
+	CreatedFrom   sql.NullString
+	CreatedBefore sql.NullString
+	UuidPrefix    sql.NullString
+}
+
+func (q *Queries) CountFilteredGames(ctx context.Context, arg CountFilteredGamesParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countFilteredGames,
+		arg.Status,
+		arg.MinGridSize,
+		arg.MaxGridSize,
+		arg.MinMines,
+		arg.MaxMines,
+		arg.CreatedFrom,
+		arg.CreatedBefore,
+		arg.UuidPrefix,
+	)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createGame = `-- name: CreateGame :one
 INSERT INTO
-    games (grid_size, mines_amount, grid_state)
+    games (grid_size, mines_amount, grid_state, win_rule, session_id)
 VALUES
-    (?, ?, ?) RETURNING id, uuid, grid_size, mines_amount, game_failed, game_won, grid_state, created_at
+    (?, ?, ?, ?, ?) RETURNING id, uuid, grid_size, mines_amount, game_failed, game_won, grid_state, created_at, win_rule, session_id, abandoned_at
 `
 
 type CreateGameParams struct {
 	GridSize    int64
 	MinesAmount int64
 	GridState   string
+	WinRule     string
+	SessionId   sql.NullString
 }
 
 func (q *Queries) CreateGame(ctx context.Context, arg CreateGameParams) (Game, error) {
-	row := q.db.QueryRowContext(ctx, createGame, arg.GridSize, arg.MinesAmount, arg.GridState)
+	row := q.db.QueryRowContext(ctx, createGame,
+		arg.GridSize,
+		arg.MinesAmount,
+		arg.GridState,
+		arg.WinRule,
+		arg.SessionId,
+	)
 	var i Game
 	err := row.Scan(
 		&i.Id,
@@ -36,13 +138,157 @@ func (q *Queries) CreateGame(ctx context.Context, arg CreateGameParams) (Game, e
 		&i.GameWon,
 		&i.GridState,
 		&i.CreatedAt,
+		&i.WinRule,
+		&i.SessionId,
+		&i.AbandonedAt,
 	)
 	return i, err
 }
 
+const deleteGameById = `-- name: DeleteGameById :execrows
+DELETE FROM
+    games
+WHERE
+    id = ?
+`
+
+func (q *Queries) DeleteGameById(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteGameById, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteUnfinishedGamesCreatedBefore = `-- name: DeleteUnfinishedGamesCreatedBefore :execrows
+DELETE FROM
+    games
+WHERE
+    created_at < ?1 AND game_failed = FALSE AND game_won = FALSE
+`
+
+// The moves of the deleted games are deleted with them by the foreign key.
+func (q *Queries) DeleteUnfinishedGamesCreatedBefore(ctx context.Context, createdBefore sql.NullTime) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUnfinishedGamesCreatedBefore, createdBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const exportFilteredGames = `-- name: ExportFilteredGames :many
+SELECT
+    games.id, games.uuid, games.grid_size, games.mines_amount, games.game_failed, games.game_won, games.grid_state,
+    games.created_at, games.win_rule, games.session_id, games.abandoned_at,
+    (SELECT COUNT(*) FROM moves WHERE moves.game_id = games.id) AS moves_count,
+    last_move.create_at AS last_move_at
+FROM
+    games
+    CROSS JOIN (SELECT CAST(?1 AS TEXT) AS uuids) AS params
+    LEFT JOIN moves AS last_move ON last_move.id = (SELECT MAX(moves.id) FROM moves WHERE moves.game_id = games.id)
+WHERE
+    (CAST(?2 AS TEXT) IS NULL
+        OR CAST(?2 AS TEXT) = CASE WHEN games.game_won THEN 'won' WHEN games.abandoned_at IS NOT NULL THEN 'abandoned' WHEN games.game_failed THEN 'lost' ELSE 'in_progress' END)
+    AND (games.grid_size >= ?3 OR ?3 IS NULL)
+    AND (games.grid_size <= ?4 OR ?4 IS NULL)
+    AND (games.mines_amount >= ?5 OR ?5 IS NULL)
+    AND (games.mines_amount <= ?6 OR ?6 IS NULL)
+    AND (games.created_at >= CAST(?7 AS TEXT) OR ?7 IS NULL)
+    AND (games.created_at < CAST(?8 AS TEXT) OR ?8 IS NULL)
+    AND (games.uuid LIKE CAST(?9 AS TEXT) || '%' OR ?9 IS NULL)
+    AND (params.uuids IS NULL OR games.uuid IN (SELECT value FROM json_each(params.uuids)))
+    AND games.id > ?10
+ORDER BY
+    games.id
+LIMIT
+    ?11
+`
+
+type ExportFilteredGamesParams struct {
+	Uuids         sql.NullString
+	Status        sql.NullString
+	MinGridSize   sql.NullInt64
+	MaxGridSize   sql.NullInt64
+	MinMines      sql.NullInt64
+	MaxMines      sql.NullInt64
+	CreatedFrom   sql.NullString
+	CreatedBefore sql.NullString
+	UuidPrefix    sql.NullString
+	AfterId       int64
+	PageSize      int64
+}
+
+type ExportFilteredGamesRow struct {
+	Id          int64
+	Uuid        string
+	GridSize    int64
+	MinesAmount int64
+	GameFailed  bool
+	GameWon     bool
+	GridState   string
+	CreatedAt   sql.NullTime
+	WinRule     string
+	SessionId   sql.NullString
+	AbandonedAt sql.NullTime
+	MovesCount  int64
+	LastMoveAt  sql.NullTime
+}
+
+// Pages through the filtered games by id for the exports, optionally only the games with the uuids of a JSON
+// array. The last move is joined for the duration of the games, games without moves have no last_move_at.
+// The uuids are selected into params first, sqlc leaves the arguments of a table function alone.
+func (q *Queries) ExportFilteredGames(ctx context.Context, arg ExportFilteredGamesParams) ([]ExportFilteredGamesRow, error) {
+	rows, err := q.db.QueryContext(ctx, exportFilteredGames,
+		arg.Uuids,
+		arg.Status,
+		arg.MinGridSize,
+		arg.MaxGridSize,
+		arg.MinMines,
+		arg.MaxMines,
+		arg.CreatedFrom,
+		arg.CreatedBefore,
+		arg.UuidPrefix,
+		arg.AfterId,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ExportFilteredGamesRow
+	for rows.Next() {
+		var i ExportFilteredGamesRow
+		if err := rows.Scan(
+			&i.Id,
+			&i.Uuid,
+			&i.GridSize,
+			&i.MinesAmount,
+			&i.GameFailed,
+			&i.GameWon,
+			&i.GridState,
+			&i.CreatedAt,
+			&i.WinRule,
+			&i.SessionId,
+			&i.AbandonedAt,
+			&i.MovesCount,
+			&i.LastMoveAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getGameById = `-- name: GetGameById :one
 SELECT
-    id, uuid, grid_size, mines_amount, game_failed, game_won, grid_state, created_at
+    id, uuid, grid_size, mines_amount, game_failed, game_won, grid_state, created_at, win_rule, session_id, abandoned_at
 FROM
     games
 WHERE
@@ -61,13 +307,16 @@ func (q *Queries) GetGameById(ctx context.Context, id int64) (Game, error) {
 		&i.GameWon,
 		&i.GridState,
 		&i.CreatedAt,
+		&i.WinRule,
+		&i.SessionId,
+		&i.AbandonedAt,
 	)
 	return i, err
 }
 
 const getGameByUuid = `-- name: GetGameByUuid :one
 SELECT
-    id, uuid, grid_size, mines_amount, game_failed, game_won, grid_state, created_at
+    id, uuid, grid_size, mines_amount, game_failed, game_won, grid_state, created_at, win_rule, session_id, abandoned_at
 FROM
     games
 WHERE
@@ -86,6 +335,9 @@ func (q *Queries) GetGameByUuid(ctx context.Context, uuid string) (Game, error)
 		&i.GameWon,
 		&i.GridState,
 		&i.CreatedAt,
+		&i.WinRule,
+		&i.SessionId,
+		&i.AbandonedAt,
 	)
 	return i, err
 }
@@ -141,6 +393,8 @@ SELECT
     COUNT(*) FILTER (WHERE game_failed = FALSE AND game_won = FALSE) AS not_finished_games
 FROM 
     games
+WHERE
+    abandoned_at IS NULL
 `
 
 type GetGamesInfoRow struct {
@@ -150,6 +404,7 @@ type GetGamesInfoRow struct {
 	NotFinishedGames int64
 }
 
+// Abandoned games were neither won nor lost, they are left out.
 func (q *Queries) GetGamesInfo(ctx context.Context) (GetGamesInfoRow, error) {
 	row := q.db.QueryRowContext(ctx, getGamesInfo)
 	var i GetGamesInfoRow
@@ -171,7 +426,7 @@ SELECT
 FROM 
     games
 WHERE 
-    uuid IN (/*SLICE:uuids*/?)
+    uuid IN (/*SLICE:uuids*/?) AND abandoned_at IS NULL
 `
 
 type GetGamesInfoByUuidsRow struct {
@@ -181,6 +436,7 @@ type GetGamesInfoByUuidsRow struct {
 	NotFinishedGames int64
 }
 
+// Abandoned games were neither won nor lost, they are left out.
 func (q *Queries) GetGamesInfoByUuids(ctx context.Context, uuids []string) (GetGamesInfoByUuidsRow, error) {
 	query := getGamesInfoByUuids
 	var queryParams []interface{}
@@ -280,6 +536,8 @@ FROM
     moves
 WHERE
     game_id = ?
+ORDER BY
+    id
 `
 
 func (q *Queries) GetMovesByGameId(ctx context.Context, gameID int64) ([]Move, error) {
@@ -359,6 +617,126 @@ func (q *Queries) InsertMove(ctx context.Context, arg InsertMoveParams) (Move, e
 	return i, err
 }
 
+const listFilteredGames = `-- name: ListFilteredGames :many
+SELECT
+    games.id, games.uuid, games.grid_size, games.mines_amount, games.game_failed, games.game_won, games.created_at,
+    games.abandoned_at
+FROM
+    games
+    CROSS JOIN (SELECT CAST(?1 AS TEXT) AS sort) AS params
+WHERE
+    (CAST(?2 AS TEXT) IS NULL
+        OR CAST(?2 AS TEXT) = CASE WHEN games.game_won THEN 'won' WHEN games.abandoned_at IS NOT NULL THEN 'abandoned' WHEN games.game_failed THEN 'lost' ELSE 'in_progress' END)
+    AND (games.grid_size >= ?3 OR ?3 IS NULL)
+    AND (games.grid_size <= ?4 OR ?4 IS NULL)
+    AND (games.mines_amount >= ?5 OR ?5 IS NULL)
+    AND (games.mines_amount <= ?6 OR ?6 IS NULL)
+    AND (games.created_at >= CAST(?7 AS TEXT) OR ?7 IS NULL)
+    AND (games.created_at < CAST(?8 AS TEXT) OR ?8 IS NULL)
+    AND (games.uuid LIKE CAST(?9 AS TEXT) || '%' OR ?9 IS NULL)
+    AND (CAST(?10 AS TEXT) IS NULL
+        OR (params.sort = 'created_at_desc'
+            AND (games.created_at, games.id) < (CAST(?10 AS TEXT), CAST(?11 AS INTEGER)))
+        OR (params.sort = 'created_at_asc'
+            AND (games.created_at, games.id) > (CAST(?10 AS TEXT), CAST(?11 AS INTEGER))))
+ORDER BY
+    CASE WHEN params.sort = 'id_asc' THEN games.id END ASC,
+    CASE WHEN params.sort = 'id_desc' THEN games.id END DESC,
+    CASE WHEN params.sort = 'uuid_asc' THEN games.uuid END ASC,
+    CASE WHEN params.sort = 'uuid_desc' THEN games.uuid END DESC,
+    CASE WHEN params.sort = 'grid_size_asc' THEN games.grid_size END ASC,
+    CASE WHEN params.sort = 'grid_size_desc' THEN games.grid_size END DESC,
+    CASE WHEN params.sort = 'mines_amount_asc' THEN games.mines_amount END ASC,
+    CASE WHEN params.sort = 'mines_amount_desc' THEN games.mines_amount END DESC,
+    CASE WHEN params.sort = 'status_asc' THEN CASE WHEN games.game_won THEN 'won' WHEN games.abandoned_at IS NOT NULL THEN 'abandoned' WHEN games.game_failed THEN 'lost' ELSE 'in_progress' END END ASC,
+    CASE WHEN params.sort = 'status_desc' THEN CASE WHEN games.game_won THEN 'won' WHEN games.abandoned_at IS NOT NULL THEN 'abandoned' WHEN games.game_failed THEN 'lost' ELSE 'in_progress' END END DESC,
+    CASE WHEN params.sort = 'created_at_asc' THEN games.created_at END ASC,
+    CASE WHEN params.sort = 'created_at_asc' THEN games.id END ASC,
+    CASE WHEN params.sort = 'created_at_desc' THEN games.created_at END DESC,
+    games.id DESC
+LIMIT ?13 OFFSET ?12
+`
+
+type ListFilteredGamesParams struct {
+	Sort            string
+	Status          sql.NullString
+	MinGridSize     sql.NullInt64
+	MaxGridSize     sql.NullInt64
+	MinMines        sql.NullInt64
+	MaxMines        sql.NullInt64
+	CreatedFrom     sql.NullString
+	CreatedBefore   sql.NullString
+	UuidPrefix      sql.NullString
+	CursorCreatedAt sql.NullString
+	CursorId        sql.NullInt64
+	PageOffset      int64
+	PageSize        int64
+}
+
+type ListFilteredGamesRow struct {
+	Id          int64
+	Uuid        string
+	GridSize    int64
+	MinesAmount int64
+	GameFailed  bool
+	GameWon     bool
+	CreatedAt   sql.NullTime
+	AbandonedAt sql.NullTime
+}
+
+// Lists the games of the admin page. Every filter is optional, NULL matches all games, and the filters are
+// the same as in CountFilteredGames. The sort argument is one of <column>_asc or <column>_desc.
+// Sorted by created_at, the list is paged from the optional cursor on instead of by the offset: towards older
+// games sorted descending, towards newer ones sorted ascending, ties are broken by the id in the same direction.
+// Other sorts break ties by the newest id. The creation bounds and the cursor are compared as text, in the
+// format SQLite stores CURRENT_TIMESTAMP in. The sort is selected into params first, sqlc leaves the arguments of an ORDER BY alone.
+// sqlc numbers the OFFSET before the LIMIT, so page_offset comes before page_size in the params.
+func (q *Queries) ListFilteredGames(ctx context.Context, arg ListFilteredGamesParams) ([]ListFilteredGamesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listFilteredGames,
+		arg.Sort,
+		arg.Status,
+		arg.MinGridSize,
+		arg.MaxGridSize,
+		arg.MinMines,
+		arg.MaxMines,
+		arg.CreatedFrom,
+		arg.CreatedBefore,
+		arg.UuidPrefix,
+		arg.CursorCreatedAt,
+		arg.CursorId,
+		arg.PageOffset,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListFilteredGamesRow
+	for rows.Next() {
+		var i ListFilteredGamesRow
+		if err := rows.Scan(
+			&i.Id,
+			&i.Uuid,
+			&i.GridSize,
+			&i.MinesAmount,
+			&i.GameFailed,
+			&i.GameWon,
+			&i.CreatedAt,
+			&i.AbandonedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listGames = `-- name: ListGames :many
 SELECT
     id, uuid, grid_size, mines_amount, game_failed, game_won, created_at
@@ -415,30 +793,109 @@ func (q *Queries) ListGames(ctx context.Context, arg ListGamesParams) ([]ListGam
 	return items, nil
 }
 
-const updateGameGridStateById = `-- name: UpdateGameGridStateById :exec
+const listLegacyGridStates = `-- name: ListLegacyGridStates :many
+SELECT
+    id, grid_size, grid_state
+FROM
+    games
+WHERE
+    id > ? AND substr(grid_state, 1, 1) <> '~'
+ORDER BY
+    id
+LIMIT ?
+`
+
+type ListLegacyGridStatesParams struct {
+	Id    int64
+	Limit int64
+}
+
+type ListLegacyGridStatesRow struct {
+	Id        int64
+	GridSize  int64
+	GridState string
+}
+
+func (q *Queries) ListLegacyGridStates(ctx context.Context, arg ListLegacyGridStatesParams) ([]ListLegacyGridStatesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listLegacyGridStates, arg.Id, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListLegacyGridStatesRow
+	for rows.Next() {
+		var i ListLegacyGridStatesRow
+		if err := rows.Scan(&i.Id, &i.GridSize, &i.GridState); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const replaceGameGridState = `-- name: ReplaceGameGridState :execrows
 UPDATE
     games
 SET
-    game_failed = ?,
-    game_won = ?,
-    grid_state = ?
+    grid_state = ?1
 WHERE
-    id = ?
+    id = ?2 AND grid_state = ?3
+`
+
+type ReplaceGameGridStateParams struct {
+	NewGridState string
+	Id           int64
+	OldGridState string
+}
+
+func (q *Queries) ReplaceGameGridState(ctx context.Context, arg ReplaceGameGridStateParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, replaceGameGridState, arg.NewGridState, arg.Id, arg.OldGridState)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateGameGridStateById = `-- name: UpdateGameGridStateById :execrows
+UPDATE
+    games
+SET
+    game_failed = ?1,
+    game_won = ?2,
+    grid_state = ?3
+WHERE
+    id = ?4
+    AND grid_state = ?5
+    AND game_failed = FALSE
+    AND game_won = FALSE
 `
 
 type UpdateGameGridStateByIdParams struct {
-	GameFailed bool
-	GameWon    bool
-	GridState  string
-	Id         int64
+	GameFailed   bool
+	GameWon      bool
+	GridState    string
+	Id           int64
+	OldGridState string
 }
 
-func (q *Queries) UpdateGameGridStateById(ctx context.Context, arg UpdateGameGridStateByIdParams) error {
-	_, err := q.db.ExecContext(ctx, updateGameGridStateById,
+// Stores the grid of an unfinished game only if it still has the old grid state it was loaded with,
+// so a concurrent move or abandon is never overwritten. Returns 0 rows when the game was changed meanwhile.
+func (q *Queries) UpdateGameGridStateById(ctx context.Context, arg UpdateGameGridStateByIdParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateGameGridStateById,
 		arg.GameFailed,
 		arg.GameWon,
 		arg.GridState,
 		arg.Id,
+		arg.OldGridState,
 	)
-	return err
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
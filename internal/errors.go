@@ -0,0 +1,154 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"minesweeper/internal/game"
+	"net/http"
+	"strings"
+)
+
+// HTTPError is an error with the status code and the message the user gets to see.
+// The wrapped Err is only logged, it may contain details that must not leave the server.
+type HTTPError struct {
+	Status  int
+	Message string
+	Err     error
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("%d %s", e.Status, e.Message)
+	}
+	return fmt.Sprintf("%d %s: %v", e.Status, e.Message, e.Err)
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+func NewHTTPError(status int, message string, err error) *HTTPError {
+	return &HTTPError{Status: status, Message: message, Err: err}
+}
+
+// BadRequest reports a request that is malformed or misses parameters.
+func BadRequest(message string, err error) *HTTPError {
+	return NewHTTPError(http.StatusBadRequest, message, err)
+}
+
+// Unauthorized reports a request that needs credentials it did not send, like one to the admin area.
+func Unauthorized(message string, err error) *HTTPError {
+	return NewHTTPError(http.StatusUnauthorized, message, err)
+}
+
+// Forbidden reports a request the user is not allowed to make, like one without a valid CSRF token.
+func Forbidden(message string, err error) *HTTPError {
+	return NewHTTPError(http.StatusForbidden, message, err)
+}
+
+// NotFound reports a resource that does not exist.
+func NotFound(message string, err error) *HTTPError {
+	return NewHTTPError(http.StatusNotFound, message, err)
+}
+
+// Conflict reports a request that doesn't fit the current state, like a move in a finished game.
+func Conflict(message string, err error) *HTTPError {
+	return NewHTTPError(http.StatusConflict, message, err)
+}
+
+// Unprocessable reports a well formed request with invalid values.
+func Unprocessable(message string, err error) *HTTPError {
+	return NewHTTPError(http.StatusUnprocessableEntity, message, err)
+}
+
+// TooManyRequests reports a client that sent more requests than it is allowed to.
+func TooManyRequests(message string, err error) *HTTPError {
+	return NewHTTPError(http.StatusTooManyRequests, message, err)
+}
+
+// Internal reports a failure on our side, the user only gets a generic message.
+func Internal(err error) *HTTPError {
+	return NewHTTPError(http.StatusInternalServerError, "Something went wrong, please try again.", err)
+}
+
+// toHTTPError maps any error returned to a handler to an HTTPError.
+// Errors of the game service get their matching status code, everything unknown is an internal error.
+func toHTTPError(err error) *HTTPError {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+
+	var settingsErr *game.SettingsError
+	switch {
+	case errors.As(err, &settingsErr):
+		return Unprocessable(settingsErr.Reason, err)
+	case errors.Is(err, game.ErrGameNotFound):
+		return NotFound("There is no game with such uuid.", err)
+	case errors.Is(err, game.ErrGameOver):
+		return Conflict("This game is already over.", err)
+	case errors.Is(err, game.ErrConcurrentMove):
+		return Conflict("This game was changed by another move, please try again.", err)
+	case errors.Is(err, game.ErrInvalidAction), errors.Is(err, game.ErrInvalidMove):
+		return Unprocessable("Invalid action.", err)
+	case errors.Is(err, game.ErrCorruptGame):
+		return NewHTTPError(http.StatusInternalServerError, "The saved state of this game is corrupted and it cannot be loaded.", err)
+	default:
+		return Internal(err)
+	}
+}
+
+// wantsHTML tells whether the error should be rendered with the error_message template.
+// HTMX requests and browsers get HTML, API clients get JSON.
+func wantsHTML(r *http.Request) bool {
+	if r.Header.Get("HX-Request") == "true" {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// writeError logs err together with the request ID and responds with its status code and safe message.
+func writeError(w http.ResponseWriter, r *http.Request, templates *template.Template, err error) {
+	httpErr := toHTTPError(err)
+	requestID := RequestIDFromContext(r.Context())
+
+	level := slog.LevelWarn
+	if httpErr.Status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	slog.Log(r.Context(), level, "Request failed", "method", r.Method, "path", r.URL.Path, "status", httpErr.Status, "error", err)
+
+	if !wantsHTML(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(httpErr.Status)
+		json.NewEncoder(w).Encode(struct {
+			Error     string `json:"error"`
+			Status    int    `json:"status"`
+			RequestID string `json:"request_id,omitempty"`
+		}{
+			Error:     httpErr.Message,
+			Status:    httpErr.Status,
+			RequestID: requestID,
+		})
+		return
+	}
+
+	responseData := struct {
+		ErrorMessage string
+		ShowCloseBtn bool
+		RequestID    string
+	}{
+		ErrorMessage: httpErr.Message,
+		ShowCloseBtn: true,
+		RequestID:    requestID,
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(httpErr.Status)
+	if err := templates.ExecuteTemplate(w, "error_message", responseData); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to render error message", "error", err)
+	}
+}
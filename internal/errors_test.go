@@ -0,0 +1,137 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"minesweeper/internal/game"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestToHTTPError(t *testing.T) {
+	testCases := []struct {
+		name            string
+		err             error
+		expectedStatus  int
+		expectedMessage string
+	}{
+		{"HTTP error", Conflict("Already done.", nil), http.StatusConflict, "Already done."},
+		{"Wrapped HTTP error", fmt.Errorf("context: %w", NotFound("Gone.", nil)), http.StatusNotFound, "Gone."},
+		{"Game not found", fmt.Errorf("%w: abc", game.ErrGameNotFound), http.StatusNotFound, "There is no game with such uuid."},
+		{"Game over", game.ErrGameOver, http.StatusConflict, "This game is already over."},
+		{"Invalid move", game.ErrInvalidMove, http.StatusUnprocessableEntity, "Invalid action."},
+		{"Invalid settings", &game.SettingsError{Reason: "grid size must be between 2 and 128"}, http.StatusUnprocessableEntity, "grid size must be between 2 and 128"},
+		{"Corrupt game", fmt.Errorf("%w: bad base64", game.ErrCorruptGame), http.StatusInternalServerError, "The saved state of this game is corrupted and it cannot be loaded."},
+		{"Unknown error", errors.New("pq: connection refused"), http.StatusInternalServerError, "Something went wrong, please try again."},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			httpErr := toHTTPError(tc.err)
+
+			if httpErr.Status != tc.expectedStatus || httpErr.Message != tc.expectedMessage {
+				t.Errorf("Expected %d '%s', but got %d '%s'", tc.expectedStatus, tc.expectedMessage, httpErr.Status, httpErr.Message)
+			}
+		})
+	}
+}
+
+func TestWriteErrorNegotiatesContent(t *testing.T) {
+	h := newTestHandler(t, newFakeGameService())
+	err := NotFound("There is no game with such uuid.", errors.New("sql: no rows in result set"))
+
+	testCases := []struct {
+		name        string
+		headers     map[string]string
+		contentType string
+	}{
+		{"HTMX request", map[string]string{"HX-Request": "true"}, "text/html"},
+		{"Browser", map[string]string{"Accept": "text/html,application/xhtml+xml"}, "text/html"},
+		{"API client", map[string]string{"Accept": "application/json"}, "application/json"},
+		{"No preference", map[string]string{}, "application/json"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/load-game", nil)
+			for key, value := range tc.headers {
+				request.Header.Set(key, value)
+			}
+			recorder := httptest.NewRecorder()
+
+			RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeError(w, r, h.Templates, err)
+			})).ServeHTTP(recorder, request)
+
+			if recorder.Code != http.StatusNotFound {
+				t.Errorf("Expected status %d, but got %d", http.StatusNotFound, recorder.Code)
+			}
+			if !strings.HasPrefix(recorder.Header().Get("Content-Type"), tc.contentType) {
+				t.Errorf("Expected content type %s, but got %s", tc.contentType, recorder.Header().Get("Content-Type"))
+			}
+
+			body := recorder.Body.String()
+			requestID := recorder.Header().Get(RequestIDHeader)
+			if !strings.Contains(body, "There is no game with such uuid.") || !strings.Contains(body, requestID) {
+				t.Errorf("Expected the message and request ID %s in the body, but got %s", requestID, body)
+			}
+			if strings.Contains(body, "sql:") {
+				t.Errorf("Expected the internal error not to be shown, but got %s", body)
+			}
+
+			if tc.contentType == "application/json" {
+				var response struct {
+					Error     string `json:"error"`
+					Status    int    `json:"status"`
+					RequestID string `json:"request_id"`
+				}
+				if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+					t.Fatalf("Expected a JSON body, but got %s", body)
+				}
+				if response.Status != http.StatusNotFound || response.RequestID != requestID {
+					t.Errorf("Unexpected JSON error %+v", response)
+				}
+			}
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFromContext(r.Context())
+	}))
+
+	testCases := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{"Generated", "", false},
+		{"Taken from proxy", "abc-123_x.y", true},
+		{"Unsafe characters", "abc\n123", false},
+		{"Too long", strings.Repeat("a", 65), false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.incoming != "" {
+				request.Header.Set(RequestIDHeader, tc.incoming)
+			}
+			recorder := httptest.NewRecorder()
+
+			handler.ServeHTTP(recorder, request)
+
+			if seen == "" || recorder.Header().Get(RequestIDHeader) != seen {
+				t.Fatalf("Expected the request ID '%s' to be sent back, but got '%s'", seen, recorder.Header().Get(RequestIDHeader))
+			}
+			if (seen == tc.incoming) != tc.keep {
+				t.Errorf("Expected keeping the incoming ID to be %v, but got '%s'", tc.keep, seen)
+			}
+		})
+	}
+}
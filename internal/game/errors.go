@@ -0,0 +1,43 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Domain errors returned by the Service. They are wrapped with details,
+// front-ends should match them with errors.Is and decide how to present them.
+var (
+	// ErrGameNotFound is returned when no game has the requested uuid.
+	ErrGameNotFound = errors.New("game not found")
+	// ErrGameOver is returned when a move is made in, or an admin abandons, a game that is already over.
+	ErrGameOver = errors.New("game is already over")
+	// ErrInvalidAction is returned for an action other than revealing or flagging a cell.
+	ErrInvalidAction = errors.New("invalid action")
+	// ErrInvalidMove is returned for a move outside of the grid.
+	ErrInvalidMove = errors.New("invalid move")
+	// ErrInvalidSettings is returned when a new game is requested with settings outside of the limits.
+	ErrInvalidSettings = errors.New("invalid game settings")
+	// ErrCorruptGame is returned when a stored game cannot be decoded, the cause is wrapped as well.
+	ErrCorruptGame = errors.New("game is corrupted")
+	// ErrConcurrentMove is returned when a move keeps being overtaken by other changes to the same game.
+	ErrConcurrentMove = errors.New("game was changed by another move")
+)
+
+// SettingsError explains which setting of a new game is invalid. Its message is meant for the player,
+// and it matches ErrInvalidSettings.
+type SettingsError struct {
+	Reason string
+}
+
+func (e *SettingsError) Error() string {
+	return e.Reason
+}
+
+func (e *SettingsError) Is(target error) bool {
+	return target == ErrInvalidSettings
+}
+
+func settingsError(format string, args ...any) *SettingsError {
+	return &SettingsError{Reason: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,35 @@
+package game
+
+import (
+	"minesweeper/internal/models"
+	"time"
+)
+
+// Observer is notified about what happens in the games played through the Service, e.g. to export
+// metrics. Game events are only reported once their transaction is committed.
+//
+// Implementations must be safe for concurrent use and should embed NopObserver, so they keep compiling
+// when events are added.
+type Observer interface {
+	// GameStarted is called for every stored new game.
+	GameStarted(game *models.Game)
+	// MoveMade is called for every recorded move.
+	MoveMade(game *models.Game, action Action)
+	// GameEnded is called once for the move that won or lost game.
+	GameEnded(game *models.Game)
+	// GameAbandoned is called for every game an admin abandoned, game has no grid.
+	GameAbandoned(game *models.Game)
+	// GridEncoded and GridDecoded report the time spent converting a grid from and to its stored state.
+	GridEncoded(duration time.Duration)
+	GridDecoded(duration time.Duration)
+}
+
+// NopObserver ignores every event.
+type NopObserver struct{}
+
+func (NopObserver) GameStarted(game *models.Game)             {}
+func (NopObserver) MoveMade(game *models.Game, action Action) {}
+func (NopObserver) GameEnded(game *models.Game)               {}
+func (NopObserver) GameAbandoned(game *models.Game)           {}
+func (NopObserver) GridEncoded(duration time.Duration)        {}
+func (NopObserver) GridDecoded(duration time.Duration)        {}
@@ -0,0 +1,94 @@
+package game
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// RetentionInterval is how often the retention job runs unless configured otherwise.
+const RetentionInterval = time.Hour
+
+// Retention configures the job removing the unfinished games nobody plays anymore, see Service.RunRetention.
+type Retention struct {
+	// MaxAge is how old an unfinished game gets before it is removed, zero turns the job off.
+	MaxAge time.Duration
+	// Interval is the time between two runs of the job.
+	Interval time.Duration
+	// Archive abandons the old games instead of deleting them, so they are kept out of the stats.
+	Archive bool
+}
+
+// DefaultRetention returns the retention job configuration, it is off until a max age is set.
+func DefaultRetention() Retention {
+	return Retention{Interval: RetentionInterval}
+}
+
+// Enabled tells whether the retention job runs at all.
+func (r Retention) Enabled() bool {
+	return r.MaxAge > 0
+}
+
+// Validate checks that the job can run with the configuration.
+func (r Retention) Validate() error {
+	switch {
+	case r.MaxAge < 0:
+		return fmt.Errorf("max age must not be negative, but got %v", r.MaxAge)
+	case r.Interval <= 0:
+		return fmt.Errorf("interval must be positive, but got %v", r.Interval)
+	}
+	return nil
+}
+
+// ParseRetentionAction parses what the retention job does with old games, delete or archive them.
+func ParseRetentionAction(action string) (archive bool, err error) {
+	switch action {
+	case "delete":
+		return false, nil
+	case "archive":
+		return true, nil
+	default:
+		return false, fmt.Errorf("retention action must be delete or archive, but got %q", action)
+	}
+}
+
+// RunRetention purges the unfinished games older than the max age of retention right away and then once
+// every interval, until ctx is done. A failed run is logged and the games are purged by the next one.
+func (s *Service) RunRetention(ctx context.Context, retention Retention) {
+	ticker := time.NewTicker(retention.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.runRetentionOnce(ctx, retention)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) runRetentionOnce(ctx context.Context, retention Retention) {
+	action := "delete"
+	if retention.Archive {
+		action = "archive"
+	}
+
+	purged, err := s.PurgeUnfinished(ctx, time.Now().Add(-retention.MaxAge), retention.Archive)
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to purge unfinished games", "action", action, "error", err)
+		return
+	}
+
+	level := slog.LevelDebug
+	if purged > 0 {
+		level = slog.LevelInfo
+	}
+	slog.Log(ctx, level, "Unfinished games purged", "action", action, "games", purged, "max_age", retention.MaxAge.String())
+}
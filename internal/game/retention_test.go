@@ -0,0 +1,64 @@
+package game
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetentionValidate(t *testing.T) {
+	if err := DefaultRetention().Validate(); err != nil || DefaultRetention().Enabled() {
+		t.Errorf("Expected the default retention to be valid and off, but got %v", err)
+	}
+
+	for _, invalid := range []Retention{
+		{MaxAge: -time.Hour, Interval: time.Hour},
+		{MaxAge: time.Hour, Interval: 0},
+	} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", invalid)
+		}
+	}
+
+	if archive, err := ParseRetentionAction("archive"); err != nil || !archive {
+		t.Errorf("Expected archive to archive, but got %v (%v)", archive, err)
+	}
+	if _, err := ParseRetentionAction("keep"); err == nil {
+		t.Errorf("Expected an unknown action to be rejected")
+	}
+}
+
+func TestRunRetention(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+
+	old := createTestGame(t, service, 4, 2)
+	finished := createTestGame(t, service, 4, 2)
+	if _, err := service.Abandon(ctx, finished.Uuid); err != nil {
+		t.Fatalf("Failed to abandon game: %v", err)
+	}
+
+	// every game is older than a max age of a nanosecond, but only the unfinished one is purged
+	retentionCtx, cancel := context.WithCancel(ctx)
+	stopped := make(chan struct{})
+	go func() {
+		service.RunRetention(retentionCtx, Retention{MaxAge: time.Nanosecond, Interval: time.Hour})
+		close(stopped)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for _, err := service.Load(ctx, old.Uuid); !errors.Is(err, ErrGameNotFound); _, err = service.Load(ctx, old.Uuid) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the first run to delete the unfinished game, but got %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	<-stopped
+
+	if _, err := service.Load(ctx, finished.Uuid); err != nil {
+		t.Errorf("Expected the finished game to be kept, but got %v", err)
+	}
+}
@@ -0,0 +1,364 @@
+// Package game holds the game use cases shared by the front-ends,
+// on top of the game rules in models and the storage repositories.
+//
+// The Service returns the domain errors from errors.go, so every front-end applies the same rules
+// and only decides how to present them.
+package game
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"minesweeper/internal/db"
+	"minesweeper/internal/models"
+	"minesweeper/internal/storage"
+	"time"
+)
+
+// Action is a move a player can make on a cell.
+type Action string
+
+const (
+	ActionRevealCell Action = "reveal_cell"
+	ActionFlagCell   Action = "flag_cell"
+)
+
+// Stats summarizes a set of games.
+type Stats struct {
+	TotalGames       int
+	WonGames         int
+	LostGames        int
+	NotFinishedGames int
+}
+
+type Service struct {
+	Repository storage.Repository
+	Transactor storage.Transactor
+	Observer   Observer
+}
+
+// NewService returns a service storing the games in repository, a nil observer is a NopObserver.
+func NewService(repository storage.Repository, transactor storage.Transactor, observer Observer) *Service {
+	if observer == nil {
+		observer = NopObserver{}
+	}
+	return &Service{Repository: repository, Transactor: transactor, Observer: observer}
+}
+
+// encodeGrid encodes grid for storage and reports the time it took.
+func (s *Service) encodeGrid(grid [][]models.Cell) string {
+	start := time.Now()
+	encoded := models.EncodeGameGrid(grid)
+	s.Observer.GridEncoded(time.Since(start))
+	return encoded
+}
+
+// Create generates a new board, stores it and returns it with the id and uuid assigned by the database.
+// sessionID records which session started the game, it may be empty.
+func (s *Service) Create(ctx context.Context, settings Settings, sessionID string) (*models.Game, error) {
+	return s.create(ctx, models.NewGame(settings.GridSize, settings.MinesAmount, settings.WinRule), sessionID)
+}
+
+// Clone stores a new, unplayed game with the same board as the game with the given uuid, see Create.
+func (s *Service) Clone(ctx context.Context, uuid string, sessionID string) (*models.Game, error) {
+	original, err := s.Load(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+	return s.create(ctx, original.CloneBoard(), sessionID)
+}
+
+func (s *Service) create(ctx context.Context, game *models.Game, sessionID string) (*models.Game, error) {
+	dbGame, err := s.Repository.CreateGame(ctx, db.CreateGameParams{
+		GridSize:    int64(game.GridSize),
+		MinesAmount: int64(game.MinesAmount),
+		GridState:   s.encodeGrid(game.Grid),
+		WinRule:     string(game.WinRule),
+		SessionId:   sql.NullString{String: sessionID, Valid: sessionID != ""},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store game: %w", err)
+	}
+
+	game.Id = dbGame.Id
+	game.Uuid = dbGame.Uuid
+
+	s.Observer.GameStarted(game)
+	return game, nil
+}
+
+// Load returns the stored game with the given uuid.
+func (s *Service) Load(ctx context.Context, uuid string) (*models.Game, error) {
+	game, _, err := s.load(ctx, s.Repository, uuid)
+	return game, err
+}
+
+// load returns the stored game with the given uuid, along with its grid state as it is stored.
+func (s *Service) load(ctx context.Context, repository storage.GameRepository, uuid string) (*models.Game, string, error) {
+	dbGame, err := repository.GetGameByUuid(ctx, uuid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, "", fmt.Errorf("%w: %s", ErrGameNotFound, uuid)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get game %s: %w", uuid, err)
+	}
+
+	start := time.Now()
+	game, err := models.FromDbGame(&dbGame)
+	s.Observer.GridDecoded(time.Since(start))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %w", ErrCorruptGame, err)
+	}
+
+	return game, dbGame.GridState, nil
+}
+
+// maxMoveAttempts is how many times Act tries a move that keeps losing the race against other moves in the same game.
+const maxMoveAttempts = 3
+
+// Act makes a move in the game with the given uuid, records it and stores the resulting grid.
+// The move and the new grid state are written in one transaction. If another move or an abandon changed the game
+// after it was loaded, the move is made again on the new state, and ErrConcurrentMove is returned after
+// maxMoveAttempts tries.
+func (s *Service) Act(ctx context.Context, uuid string, action Action, row int, col int) (*models.Game, error) {
+	if action != ActionRevealCell && action != ActionFlagCell {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidAction, action)
+	}
+
+	var game *models.Game
+	var err error
+
+	for attempt := 0; attempt < maxMoveAttempts; attempt++ {
+		err = s.Transactor.InTx(ctx, func(repository storage.Repository) error {
+			game, err = s.act(ctx, repository, uuid, action, row, col)
+			return err
+		})
+		if !errors.Is(err, ErrConcurrentMove) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.Observer.MoveMade(game, action)
+	// a finished game rejects any further move, so this is the move that ended it
+	if game.GameWon || game.GameFailed {
+		s.Observer.GameEnded(game)
+	}
+
+	return game, nil
+}
+
+func (s *Service) act(ctx context.Context, repository storage.Repository, uuid string, action Action, row int, col int) (*models.Game, error) {
+	game, gridState, err := s.load(ctx, repository, uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	if game.GameFailed || game.GameWon {
+		return nil, fmt.Errorf("%w: %s", ErrGameOver, uuid)
+	}
+
+	if row < 0 || row >= game.GridSize || col < 0 || col >= game.GridSize {
+		return nil, fmt.Errorf("%w: cell %d,%d is outside of the %dx%d grid", ErrInvalidMove, row, col, game.GridSize, game.GridSize)
+	}
+
+	switch action {
+	case ActionRevealCell:
+		game.RevealCell(row, col)
+	case ActionFlagCell:
+		game.FlagCell(row, col)
+	}
+
+	if _, err := repository.InsertMove(ctx, db.InsertMoveParams{
+		GameId:   game.Id,
+		MoveType: string(action),
+		Row:      int64(row),
+		Col:      int64(col),
+	}); err != nil {
+		return nil, fmt.Errorf("failed to record move: %w", err)
+	}
+
+	// the update only applies to the grid state that was loaded, so concurrent moves can't overwrite each other
+	updated, err := repository.UpdateGameGridStateById(ctx, db.UpdateGameGridStateByIdParams{
+		GameFailed:   game.GameFailed,
+		GameWon:      game.GameWon,
+		GridState:    s.encodeGrid(game.Grid),
+		Id:           game.Id,
+		OldGridState: gridState,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update game state: %w", err)
+	}
+	if updated == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrConcurrentMove, uuid)
+	}
+
+	return game, nil
+}
+
+// Abandon ends the unfinished game with the given uuid. It can't be played anymore, but unlike a lost game it is
+// left out of the win and loss stats. The returned game has no grid.
+// A game that is already over can't be abandoned and gives ErrGameOver.
+func (s *Service) Abandon(ctx context.Context, uuid string) (*models.Game, error) {
+	var game *models.Game
+
+	err := s.Transactor.InTx(ctx, func(repository storage.Repository) error {
+		var err error
+		game, err = s.abandon(ctx, repository, uuid)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.Observer.GameAbandoned(game)
+	return game, nil
+}
+
+// AbandonAll ends the unfinished games with the given uuids at once and returns how many were abandoned.
+// Unknown games and games that are already over are skipped.
+func (s *Service) AbandonAll(ctx context.Context, uuids []string) (int, error) {
+	var games []*models.Game
+
+	err := s.Transactor.InTx(ctx, func(repository storage.Repository) error {
+		for _, uuid := range uuids {
+			game, err := s.abandon(ctx, repository, uuid)
+			if errors.Is(err, ErrGameNotFound) || errors.Is(err, ErrGameOver) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			games = append(games, game)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, game := range games {
+		s.Observer.GameAbandoned(game)
+	}
+	return len(games), nil
+}
+
+// abandon ends the game without decoding its grid, so that games with a corrupt grid can be abandoned too.
+// The returned game has no grid.
+func (s *Service) abandon(ctx context.Context, repository storage.Repository, uuid string) (*models.Game, error) {
+	dbGame, err := repository.AbandonGameByUuid(ctx, uuid)
+	if errors.Is(err, sql.ErrNoRows) {
+		// either there is no such game or it is already over
+		_, err = repository.GetGameByUuid(ctx, uuid)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrGameNotFound, uuid)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get game %s: %w", uuid, err)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrGameOver, uuid)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to abandon game: %w", err)
+	}
+
+	return &models.Game{
+		Id:          dbGame.Id,
+		Uuid:        dbGame.Uuid,
+		GridSize:    int(dbGame.GridSize),
+		MinesAmount: int(dbGame.MinesAmount),
+		WinRule:     models.WinRule(dbGame.WinRule),
+		GameFailed:  dbGame.GameFailed,
+		GameWon:     dbGame.GameWon,
+	}, nil
+}
+
+// Delete removes the game with the given uuid, its moves are removed with it.
+func (s *Service) Delete(ctx context.Context, uuid string) error {
+	return s.Transactor.InTx(ctx, func(repository storage.Repository) error {
+		return s.delete(ctx, repository, uuid)
+	})
+}
+
+// DeleteAll removes the games with the given uuids at once and returns how many were removed.
+// Unknown games are skipped.
+func (s *Service) DeleteAll(ctx context.Context, uuids []string) (int, error) {
+	var deleted int
+
+	err := s.Transactor.InTx(ctx, func(repository storage.Repository) error {
+		for _, uuid := range uuids {
+			err := s.delete(ctx, repository, uuid)
+			if errors.Is(err, ErrGameNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			deleted++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return deleted, nil
+}
+
+func (s *Service) delete(ctx context.Context, repository storage.Repository, uuid string) error {
+	dbGame, err := repository.GetGameByUuid(ctx, uuid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %s", ErrGameNotFound, uuid)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get game %s: %w", uuid, err)
+	}
+
+	if _, err := repository.DeleteGameById(ctx, dbGame.Id); err != nil {
+		return fmt.Errorf("failed to delete game: %w", err)
+	}
+	return nil
+}
+
+// PurgeUnfinished deletes, or with archive abandons, the unfinished games created before createdBefore
+// and returns how many there were. Deleting them also deletes their moves.
+func (s *Service) PurgeUnfinished(ctx context.Context, createdBefore time.Time, archive bool) (int64, error) {
+	// created_at is stored in UTC
+	before := sql.NullTime{Time: createdBefore.UTC(), Valid: true}
+
+	if archive {
+		abandoned, err := s.Repository.AbandonUnfinishedGamesCreatedBefore(ctx, before)
+		if err != nil {
+			return 0, fmt.Errorf("failed to abandon unfinished games: %w", err)
+		}
+		return abandoned, nil
+	}
+
+	deleted, err := s.Repository.DeleteUnfinishedGamesCreatedBefore(ctx, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete unfinished games: %w", err)
+	}
+	return deleted, nil
+}
+
+// Stats summarizes the games with the given uuids, unknown uuids are ignored.
+func (s *Service) Stats(ctx context.Context, uuids []string) (Stats, error) {
+	if len(uuids) == 0 {
+		return Stats{}, nil
+	}
+
+	info, err := s.Repository.GetGamesInfoByUuids(ctx, uuids)
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to get games info: %w", err)
+	}
+
+	return Stats{
+		TotalGames:       int(info.TotalGames),
+		WonGames:         int(info.WonGames),
+		LostGames:        int(info.LostGames),
+		NotFinishedGames: int(info.NotFinishedGames),
+	}, nil
+}
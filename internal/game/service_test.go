@@ -0,0 +1,514 @@
+package game
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"minesweeper/internal/db"
+	"minesweeper/internal/models"
+	"minesweeper/internal/storage"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+
+	database, err := storage.Open(filepath.Join(t.TempDir(), "minesweeper.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	if _, _, err := database.Migrate(context.Background()); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+
+	return NewService(database.Repository(), database, nil)
+}
+
+func TestCreate(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+
+	game, err := service.Create(ctx, Settings{GridSize: 5, MinesAmount: 4, WinRule: models.WinRuleStrict}, "")
+	if err != nil {
+		t.Fatalf("Failed to create game: %v", err)
+	}
+
+	if game.Id == 0 || game.Uuid == "" {
+		t.Fatalf("Expected the game to get an id and uuid, but got %d and '%s'", game.Id, game.Uuid)
+	}
+
+	dbGame, err := service.Repository.GetGameByUuid(ctx, game.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to read back created game: %v", err)
+	}
+
+	storedGame, err := models.FromDbGame(&dbGame)
+	if err != nil {
+		t.Fatalf("Failed to decode created game: %v", err)
+	}
+
+	if storedGame.Id != game.Id || storedGame.MinesAmount != 4 || storedGame.WinRule != models.WinRuleStrict {
+		t.Errorf("Expected the stored game to match the created one, but got %+v", storedGame)
+	}
+	if models.EncodeGameGrid(storedGame.Grid) != models.EncodeGameGrid(game.Grid) {
+		t.Errorf("Expected the stored grid to match the created one")
+	}
+}
+
+func createTestGame(t *testing.T, service *Service, gridSize int, minesAmount int) *models.Game {
+	t.Helper()
+
+	game, err := service.Create(context.Background(), Settings{GridSize: gridSize, MinesAmount: minesAmount, WinRule: models.WinRuleClassic}, "")
+	if err != nil {
+		t.Fatalf("Failed to create game: %v", err)
+	}
+
+	return game
+}
+
+// safeCell returns the position of a cell without a mine.
+func safeCell(t *testing.T, game *models.Game) (int, int) {
+	t.Helper()
+
+	for row := range game.Grid {
+		for col := range game.Grid[row] {
+			if !game.Grid[row][col].HasMine {
+				return row, col
+			}
+		}
+	}
+
+	t.Fatalf("Game %s has no safe cell", game.Uuid)
+	return 0, 0
+}
+
+func TestLoad(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+	created := createTestGame(t, service, 4, 2)
+
+	loaded, err := service.Load(ctx, created.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to load game: %v", err)
+	}
+	if loaded.Id != created.Id || loaded.MinesAmount != created.MinesAmount {
+		t.Errorf("Expected to load game %d, but got %+v", created.Id, loaded)
+	}
+
+	if _, err := service.Load(ctx, "missing"); !errors.Is(err, ErrGameNotFound) {
+		t.Errorf("Expected ErrGameNotFound, but got %v", err)
+	}
+}
+
+func TestLoadCorruptGame(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+	created := createTestGame(t, service, 4, 2)
+
+	if _, err := service.Repository.ReplaceGameGridState(ctx, db.ReplaceGameGridStateParams{
+		NewGridState: "~broken",
+		Id:           created.Id,
+		OldGridState: models.EncodeGameGrid(created.Grid),
+	}); err != nil {
+		t.Fatalf("Failed to corrupt game: %v", err)
+	}
+
+	_, err := service.Load(ctx, created.Uuid)
+	if !errors.Is(err, ErrCorruptGame) || !errors.Is(err, models.ErrCorruptGridState) {
+		t.Errorf("Expected ErrCorruptGame wrapping ErrCorruptGridState, but got %v", err)
+	}
+}
+
+func TestAct(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+	created := createTestGame(t, service, 4, 2)
+	row, col := safeCell(t, created)
+
+	played, err := service.Act(ctx, created.Uuid, ActionRevealCell, row, col)
+	if err != nil {
+		t.Fatalf("Failed to act: %v", err)
+	}
+	if !played.Grid[row][col].IsRevealed {
+		t.Errorf("Expected cell %d,%d to be revealed", row, col)
+	}
+
+	stored, err := service.Load(ctx, created.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to load game: %v", err)
+	}
+	if !stored.Grid[row][col].IsRevealed {
+		t.Errorf("Expected the revealed cell to be stored")
+	}
+
+	moves, err := service.Repository.GetMovesByGameId(ctx, created.Id)
+	if err != nil {
+		t.Fatalf("Failed to get moves: %v", err)
+	}
+	if len(moves) != 1 || moves[0].MoveType != string(ActionRevealCell) || moves[0].Row != int64(row) || moves[0].Col != int64(col) {
+		t.Errorf("Expected the move to be recorded, but got %+v", moves)
+	}
+}
+
+func TestActErrors(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+	created := createTestGame(t, service, 4, 2)
+
+	testCases := []struct {
+		name     string
+		uuid     string
+		action   Action
+		row      int
+		col      int
+		expected error
+	}{
+		{"Unknown game", "missing", ActionFlagCell, 0, 0, ErrGameNotFound},
+		{"Unknown action", created.Uuid, Action("explode"), 0, 0, ErrInvalidAction},
+		{"Row outside of grid", created.Uuid, ActionFlagCell, 4, 0, ErrInvalidMove},
+		{"Negative column", created.Uuid, ActionRevealCell, 0, -1, ErrInvalidMove},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := service.Act(ctx, tc.uuid, tc.action, tc.row, tc.col); !errors.Is(err, tc.expected) {
+				t.Errorf("Expected %v, but got %v", tc.expected, err)
+			}
+		})
+	}
+
+	moves, err := service.Repository.GetMovesByGameId(ctx, created.Id)
+	if err != nil || len(moves) != 0 {
+		t.Errorf("Expected rejected moves not to be recorded, but got %d (%v)", len(moves), err)
+	}
+}
+
+func TestActOnFinishedGame(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+	created := createTestGame(t, service, 4, 2)
+
+	var mineRow, mineCol int
+	for row := range created.Grid {
+		for col := range created.Grid[row] {
+			if created.Grid[row][col].HasMine {
+				mineRow, mineCol = row, col
+			}
+		}
+	}
+
+	lost, err := service.Act(ctx, created.Uuid, ActionRevealCell, mineRow, mineCol)
+	if err != nil {
+		t.Fatalf("Failed to act: %v", err)
+	}
+	if !lost.GameFailed {
+		t.Fatalf("Expected revealing a mine to fail the game")
+	}
+
+	if _, err := service.Act(ctx, created.Uuid, ActionFlagCell, 0, 0); !errors.Is(err, ErrGameOver) {
+		t.Errorf("Expected ErrGameOver, but got %v", err)
+	}
+}
+
+// staleTransactor hands out repositories that return a snapshot of a game taken earlier, as if another move
+// changed the game between loading and storing it. Only the first loads read the snapshot.
+type staleTransactor struct {
+	storage.Transactor
+	snapshot   db.Game
+	staleLoads int
+}
+
+func (s *staleTransactor) InTx(ctx context.Context, fn func(repository storage.Repository) error) error {
+	return s.Transactor.InTx(ctx, func(repository storage.Repository) error {
+		return fn(staleRepository{Repository: repository, transactor: s})
+	})
+}
+
+type staleRepository struct {
+	storage.Repository
+	transactor *staleTransactor
+}
+
+func (r staleRepository) GetGameByUuid(ctx context.Context, uuid string) (db.Game, error) {
+	if r.transactor.staleLoads > 0 {
+		r.transactor.staleLoads--
+		return r.transactor.snapshot, nil
+	}
+	return r.Repository.GetGameByUuid(ctx, uuid)
+}
+
+func TestActConcurrentMove(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+	created := createTestGame(t, service, 4, 2)
+
+	snapshot, err := service.Repository.GetGameByUuid(ctx, created.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to get game: %v", err)
+	}
+	if _, err := service.Act(ctx, created.Uuid, ActionFlagCell, 0, 0); err != nil {
+		t.Fatalf("Failed to act: %v", err)
+	}
+
+	transactor := &staleTransactor{Transactor: service.Transactor, snapshot: snapshot, staleLoads: 1}
+	service.Transactor = transactor
+
+	// the move made on the snapshot is rejected and made again on the game as it is now
+	game, err := service.Act(ctx, created.Uuid, ActionFlagCell, 1, 1)
+	if err != nil {
+		t.Fatalf("Failed to act: %v", err)
+	}
+	if !game.Grid[0][0].IsFlagged || !game.Grid[1][1].IsFlagged {
+		t.Errorf("Expected both flags to be kept, but got %+v", game.Grid)
+	}
+
+	transactor.staleLoads = maxMoveAttempts
+	if _, err := service.Act(ctx, created.Uuid, ActionFlagCell, 2, 2); !errors.Is(err, ErrConcurrentMove) {
+		t.Errorf("Expected ErrConcurrentMove, but got %v", err)
+	}
+
+	stored, err := service.Load(ctx, created.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to load game: %v", err)
+	}
+	if !stored.Grid[0][0].IsFlagged || !stored.Grid[1][1].IsFlagged || stored.Grid[2][2].IsFlagged {
+		t.Errorf("Expected only the moves that won the race to be stored, but got %+v", stored.Grid)
+	}
+
+	moves, err := service.Repository.GetMovesByGameId(ctx, created.Id)
+	if err != nil || len(moves) != 2 {
+		t.Errorf("Expected the rejected attempts not to be recorded, but got %d moves (%v)", len(moves), err)
+	}
+}
+
+func TestClone(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+	original := createTestGame(t, service, 5, 4)
+
+	row, col := safeCell(t, original)
+	if _, err := service.Act(ctx, original.Uuid, ActionRevealCell, row, col); err != nil {
+		t.Fatalf("Failed to act: %v", err)
+	}
+
+	clone, err := service.Clone(ctx, original.Uuid, "session-1")
+	if err != nil {
+		t.Fatalf("Failed to clone game: %v", err)
+	}
+	if clone.Uuid == original.Uuid || clone.RevealedCells != 0 {
+		t.Errorf("Expected a new game with nothing revealed, but got %+v", clone)
+	}
+	for row := range original.Grid {
+		for col := range original.Grid[row] {
+			if clone.Grid[row][col].HasMine != original.Grid[row][col].HasMine {
+				t.Fatalf("Expected the clone to have the mines of the original game")
+			}
+		}
+	}
+
+	dbGame, err := service.Repository.GetGameByUuid(ctx, clone.Uuid)
+	if err != nil || dbGame.SessionId.String != "session-1" {
+		t.Errorf("Expected the clone to belong to its session, but got %+v (%v)", dbGame.SessionId, err)
+	}
+
+	if _, err := service.Clone(ctx, "missing", ""); !errors.Is(err, ErrGameNotFound) {
+		t.Errorf("Expected ErrGameNotFound, but got %v", err)
+	}
+}
+
+func TestAbandon(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+	created := createTestGame(t, service, 4, 2)
+
+	abandoned, err := service.Abandon(ctx, created.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to abandon game: %v", err)
+	}
+	if !abandoned.GameFailed {
+		t.Errorf("Expected the abandoned game to be over")
+	}
+
+	dbGame, err := service.Repository.GetGameByUuid(ctx, created.Uuid)
+	if err != nil || !dbGame.AbandonedAt.Valid {
+		t.Errorf("Expected the game to be stored as abandoned, but got %+v (%v)", dbGame.AbandonedAt, err)
+	}
+
+	if _, err := service.Abandon(ctx, created.Uuid); !errors.Is(err, ErrGameOver) {
+		t.Errorf("Expected ErrGameOver for a game that is already over, but got %v", err)
+	}
+	if _, err := service.Act(ctx, created.Uuid, ActionFlagCell, 0, 0); !errors.Is(err, ErrGameOver) {
+		t.Errorf("Expected an abandoned game to reject moves, but got %v", err)
+	}
+	if _, err := service.Abandon(ctx, "missing"); !errors.Is(err, ErrGameNotFound) {
+		t.Errorf("Expected ErrGameNotFound, but got %v", err)
+	}
+}
+
+func TestAbandonCorruptGame(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+	created := createTestGame(t, service, 4, 2)
+
+	if _, err := service.Repository.ReplaceGameGridState(ctx, db.ReplaceGameGridStateParams{
+		NewGridState: "~broken",
+		Id:           created.Id,
+		OldGridState: models.EncodeGameGrid(created.Grid),
+	}); err != nil {
+		t.Fatalf("Failed to corrupt game: %v", err)
+	}
+
+	// the grid is never decoded, a game that can't be loaded can still be ended
+	abandoned, err := service.AbandonAll(ctx, []string{created.Uuid})
+	if err != nil || abandoned != 1 {
+		t.Errorf("Expected the corrupt game to be abandoned, but got %d (%v)", abandoned, err)
+	}
+	dbGame, err := service.Repository.GetGameByUuid(ctx, created.Uuid)
+	if err != nil || !dbGame.AbandonedAt.Valid || dbGame.GridState != "~broken" {
+		t.Errorf("Expected the game to be stored as abandoned with its grid untouched, but got %+v (%v)", dbGame, err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+	created := createTestGame(t, service, 4, 2)
+
+	if _, err := service.Act(ctx, created.Uuid, ActionFlagCell, 0, 0); err != nil {
+		t.Fatalf("Failed to act: %v", err)
+	}
+
+	if err := service.Delete(ctx, created.Uuid); err != nil {
+		t.Fatalf("Failed to delete game: %v", err)
+	}
+	if _, err := service.Load(ctx, created.Uuid); !errors.Is(err, ErrGameNotFound) {
+		t.Errorf("Expected the game to be gone, but got %v", err)
+	}
+	moves, err := service.Repository.GetMovesByGameId(ctx, created.Id)
+	if err != nil || len(moves) != 0 {
+		t.Errorf("Expected the moves to be deleted with the game, but got %d (%v)", len(moves), err)
+	}
+
+	if err := service.Delete(ctx, created.Uuid); !errors.Is(err, ErrGameNotFound) {
+		t.Errorf("Expected ErrGameNotFound, but got %v", err)
+	}
+}
+
+func TestAbandonAllAndDeleteAll(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+	first := createTestGame(t, service, 4, 2)
+	second := createTestGame(t, service, 4, 2)
+	finished := createTestGame(t, service, 4, 2)
+
+	if _, err := service.Abandon(ctx, finished.Uuid); err != nil {
+		t.Fatalf("Failed to abandon game: %v", err)
+	}
+
+	abandoned, err := service.AbandonAll(ctx, []string{first.Uuid, second.Uuid, finished.Uuid, "missing"})
+	if err != nil || abandoned != 2 {
+		t.Errorf("Expected the 2 unfinished games to be abandoned, but got %d (%v)", abandoned, err)
+	}
+
+	deleted, err := service.DeleteAll(ctx, []string{first.Uuid, finished.Uuid, "missing"})
+	if err != nil || deleted != 2 {
+		t.Errorf("Expected the 2 known games to be deleted, but got %d (%v)", deleted, err)
+	}
+	if _, err := service.Load(ctx, second.Uuid); err != nil {
+		t.Errorf("Expected the game that wasn't selected to be kept, but got %v", err)
+	}
+}
+
+func TestStats(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+
+	stats, err := service.Stats(ctx, nil)
+	if err != nil || stats != (Stats{}) {
+		t.Errorf("Expected empty stats without games, but got %+v (%v)", stats, err)
+	}
+
+	first := createTestGame(t, service, 4, 2)
+	second := createTestGame(t, service, 4, 2)
+	createTestGame(t, service, 4, 2)
+
+	stats, err = service.Stats(ctx, []string{first.Uuid, second.Uuid, "missing"})
+	if err != nil {
+		t.Fatalf("Failed to get stats: %v", err)
+	}
+	if stats != (Stats{TotalGames: 2, NotFinishedGames: 2}) {
+		t.Errorf("Expected stats of the two given games, but got %+v", stats)
+	}
+}
+
+// recordingObserver records the game events it is notified about.
+type recordingObserver struct {
+	NopObserver
+	events  []string
+	encoded int
+	decoded int
+}
+
+func (o *recordingObserver) GameStarted(game *models.Game) {
+	o.events = append(o.events, fmt.Sprintf("started %d", game.GridSize))
+}
+
+func (o *recordingObserver) MoveMade(game *models.Game, action Action) {
+	o.events = append(o.events, "move "+string(action))
+}
+
+func (o *recordingObserver) GameEnded(game *models.Game) {
+	o.events = append(o.events, fmt.Sprintf("ended won=%v", game.GameWon))
+}
+
+func (o *recordingObserver) GameAbandoned(game *models.Game) {
+	o.events = append(o.events, fmt.Sprintf("abandoned %d", game.GridSize))
+}
+
+func (o *recordingObserver) GridEncoded(duration time.Duration) { o.encoded++ }
+func (o *recordingObserver) GridDecoded(duration time.Duration) { o.decoded++ }
+
+func TestServiceNotifiesObserver(t *testing.T) {
+	service := newTestService(t)
+	observer := &recordingObserver{}
+	service.Observer = observer
+	ctx := context.Background()
+
+	created := createTestGame(t, service, 4, 2)
+
+	var mineRow, mineCol int
+	for row := range created.Grid {
+		for col := range created.Grid[row] {
+			if created.Grid[row][col].HasMine {
+				mineRow, mineCol = row, col
+			}
+		}
+	}
+
+	if _, err := service.Act(ctx, created.Uuid, ActionRevealCell, mineRow, mineCol); err != nil {
+		t.Fatalf("Failed to act: %v", err)
+	}
+	// moves in a finished game are rejected and not reported
+	if _, err := service.Act(ctx, created.Uuid, ActionFlagCell, mineRow, mineCol); !errors.Is(err, ErrGameOver) {
+		t.Fatalf("Expected ErrGameOver, but got %v", err)
+	}
+
+	// an abandoned game is reported apart from the lost ones
+	abandoned := createTestGame(t, service, 5, 2)
+	if _, err := service.Abandon(ctx, abandoned.Uuid); err != nil {
+		t.Fatalf("Failed to abandon game: %v", err)
+	}
+
+	expected := []string{"started 4", "move reveal_cell", "ended won=false", "started 5", "abandoned 5"}
+	if strings.Join(observer.events, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("Expected events %q, but got %q", expected, observer.events)
+	}
+	if observer.encoded != 3 || observer.decoded != 2 {
+		t.Errorf("Expected 3 encoded and 2 decoded grids, but got %d and %d", observer.encoded, observer.decoded)
+	}
+}
@@ -0,0 +1,145 @@
+package game
+
+import (
+	"fmt"
+	"math/rand"
+	"minesweeper/internal/models"
+	"strconv"
+)
+
+// The default limits of new games.
+const (
+	MinGridSize = 2
+	MaxGridSize = 128
+	// MaxRandomGridSize caps randomly picked grid sizes, so a random game still fits on a regular screen.
+	MaxRandomGridSize = 22
+	MinMinesRatio     = 0.1
+	MaxMinesRatio     = 0.8
+	// MaxUnfinishedGames caps the games a session may have in progress, so a script can't fill the database.
+	MaxUnfinishedGames = 20
+)
+
+// Limits bound the settings a new game can be started with.
+type Limits struct {
+	MinGridSize       int
+	MaxGridSize       int
+	MaxRandomGridSize int
+	// MinMinesRatio and MaxMinesRatio bound the share of cells that are mines.
+	MinMinesRatio float64
+	MaxMinesRatio float64
+	// MaxUnfinishedGames caps the unfinished games of a session, zero is no cap.
+	MaxUnfinishedGames int
+}
+
+// DefaultLimits returns the limits games are started with unless configured otherwise.
+func DefaultLimits() Limits {
+	return Limits{
+		MinGridSize:        MinGridSize,
+		MaxGridSize:        MaxGridSize,
+		MaxRandomGridSize:  MaxRandomGridSize,
+		MinMinesRatio:      MinMinesRatio,
+		MaxMinesRatio:      MaxMinesRatio,
+		MaxUnfinishedGames: MaxUnfinishedGames,
+	}
+}
+
+// Validate checks that the limits allow at least one game.
+func (l Limits) Validate() error {
+	switch {
+	case l.MinGridSize < MinGridSize:
+		return fmt.Errorf("min grid size must be at least %d, but got %d", MinGridSize, l.MinGridSize)
+	case l.MaxGridSize < l.MinGridSize:
+		return fmt.Errorf("max grid size %d is smaller than the min grid size %d", l.MaxGridSize, l.MinGridSize)
+	case l.MaxRandomGridSize < l.MinGridSize || l.MaxRandomGridSize > l.MaxGridSize:
+		return fmt.Errorf("max random grid size must be between %d and %d, but got %d", l.MinGridSize, l.MaxGridSize, l.MaxRandomGridSize)
+	case l.MinMinesRatio < 0 || l.MaxMinesRatio > 1 || l.MinMinesRatio > l.MaxMinesRatio:
+		return fmt.Errorf("mines ratios must satisfy 0 <= min <= max <= 1, but got %v and %v", l.MinMinesRatio, l.MaxMinesRatio)
+	case int(float64(l.MinGridSize*l.MinGridSize)*l.MaxMinesRatio) < 1:
+		return fmt.Errorf("max mines ratio %v leaves no room for a mine on the smallest grid", l.MaxMinesRatio)
+	case l.MaxUnfinishedGames < 0:
+		return fmt.Errorf("max unfinished games must not be negative, but got %d", l.MaxUnfinishedGames)
+	}
+	return nil
+}
+
+// Settings are the validated options a new game is started with.
+type Settings struct {
+	GridSize    int
+	MinesAmount int
+	WinRule     models.WinRule
+}
+
+// ParseSettings validates the submitted settings of a new game against the default limits, see Limits.ParseSettings.
+func ParseSettings(gridSizeStr, minesAmountStr, randomMinesStr, randomGridSizeStr, winRuleStr string) (Settings, error) {
+	return DefaultLimits().ParseSettings(gridSizeStr, minesAmountStr, randomMinesStr, randomGridSizeStr, winRuleStr)
+}
+
+// ParseSettings validates the submitted settings of a new game and picks the random values asked for.
+// Invalid settings are reported as a *SettingsError.
+func (l Limits) ParseSettings(gridSizeStr, minesAmountStr, randomMinesStr, randomGridSizeStr, winRuleStr string) (Settings, error) {
+	var (
+		gridSize, minesAmount       int
+		gridSizeErr, minesAmountErr error
+	)
+
+	// Check if grid size should be random or user-defined, if so check if it's within accepted bounds
+	if randomGridSizeStr == "on" {
+		gridSize = rand.Intn(l.MaxRandomGridSize-l.MinGridSize+1) + l.MinGridSize
+		gridSizeErr = nil
+	} else {
+		gridSize, gridSizeErr = strconv.Atoi(gridSizeStr)
+		if gridSizeErr != nil {
+			return Settings{}, settingsError("invalid grid size: must be a proper grid size number")
+		}
+
+		if gridSize < l.MinGridSize || gridSize > l.MaxGridSize {
+			return Settings{}, settingsError("grid size must be between %d and %d", l.MinGridSize, l.MaxGridSize)
+		}
+
+	}
+
+	// small grids would otherwise allow a random game without any mine
+	minMines := max(int(float64(gridSize*gridSize)*l.MinMinesRatio), 1)
+	maxMines := int(float64(gridSize*gridSize) * l.MaxMinesRatio)
+
+	// Check if mines amount should be random or user-defined, if so check if it's within accepted bounds
+	if randomMinesStr == "on" {
+		if gridSize > 0 {
+
+			minesAmount = rand.Intn((maxMines - minMines + 1)) + minMines
+			minesAmountErr = nil
+		} else {
+			return Settings{}, settingsError("grid size must be valid when using random mines")
+		}
+	} else {
+		minesAmount, minesAmountErr = strconv.Atoi(minesAmountStr)
+
+		if minesAmountErr != nil {
+			return Settings{}, settingsError("invalid mines amount: must be a number")
+		}
+
+		if minesAmount <= 0 || minesAmount > maxMines {
+			return Settings{}, settingsError("mines amount must be between 1 and %v of the grid size", maxMines)
+		}
+	}
+
+	if gridSizeErr != nil || minesAmountErr != nil {
+		return Settings{}, settingsError("invalid input values")
+	}
+
+	// games started without picking a rule are played with the classic one
+	winRule := models.WinRuleClassic
+	if winRuleStr != "" {
+		var winRuleErr error
+		winRule, winRuleErr = models.ParseWinRule(winRuleStr)
+		if winRuleErr != nil {
+			return Settings{}, settingsError("invalid win rule: must be either classic or strict")
+		}
+	}
+
+	return Settings{
+		GridSize:    gridSize,
+		MinesAmount: minesAmount,
+		WinRule:     winRule,
+	}, nil
+}
@@ -0,0 +1,99 @@
+package game
+
+import (
+	"errors"
+	"minesweeper/internal/models"
+	"testing"
+)
+
+func TestParseSettings(t *testing.T) {
+	testCases := []struct {
+		name                                           string
+		gridSize, minesAmount, randomMines, randomGrid string
+		winRule                                        string
+		expected                                       Settings
+		expectedErr                                    bool
+	}{
+		{"Valid settings", "10", "20", "", "", "strict", Settings{GridSize: 10, MinesAmount: 20, WinRule: models.WinRuleStrict}, false},
+		{"Classic rule by default", "5", "3", "", "", "", Settings{GridSize: 5, MinesAmount: 3, WinRule: models.WinRuleClassic}, false},
+		{"Grid too small", "1", "1", "", "", "", Settings{}, true},
+		{"Grid too large", "129", "1", "", "", "", Settings{}, true},
+		{"Grid size not a number", "ten", "1", "", "", "", Settings{}, true},
+		{"Too many mines", "5", "21", "", "", "", Settings{}, true},
+		{"No mines", "5", "0", "", "", "", Settings{}, true},
+		{"Unknown win rule", "5", "3", "", "", "sudden-death", Settings{}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			settings, err := ParseSettings(tc.gridSize, tc.minesAmount, tc.randomMines, tc.randomGrid, tc.winRule)
+
+			if tc.expectedErr {
+				var settingsErr *SettingsError
+				if !errors.Is(err, ErrInvalidSettings) || !errors.As(err, &settingsErr) {
+					t.Errorf("Expected a SettingsError matching ErrInvalidSettings, but got %v", err)
+				}
+				return
+			}
+
+			if err != nil || settings != tc.expected {
+				t.Errorf("Expected %+v, but got %+v (%v)", tc.expected, settings, err)
+			}
+		})
+	}
+}
+
+func TestParseSettingsRandom(t *testing.T) {
+	for run := 0; run < 100; run++ {
+		settings, err := ParseSettings("", "", "on", "on", "")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		maxMines := int(float64(settings.GridSize*settings.GridSize) * MaxMinesRatio)
+		if settings.GridSize < MinGridSize || settings.GridSize > MaxRandomGridSize || settings.MinesAmount < 1 || settings.MinesAmount > maxMines {
+			t.Fatalf("Random settings out of bounds: %+v", settings)
+		}
+	}
+}
+
+func TestLimitsParseSettings(t *testing.T) {
+	limits := Limits{MinGridSize: 4, MaxGridSize: 8, MaxRandomGridSize: 6, MinMinesRatio: 0.2, MaxMinesRatio: 0.5}
+
+	if _, err := limits.ParseSettings("10", "5", "", "", ""); !errors.Is(err, ErrInvalidSettings) {
+		t.Errorf("Expected a grid above the configured max to be rejected, but got %v", err)
+	}
+	if _, err := limits.ParseSettings("4", "9", "", "", ""); !errors.Is(err, ErrInvalidSettings) {
+		t.Errorf("Expected more mines than the configured ratio to be rejected, but got %v", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		settings, err := limits.ParseSettings("", "", "on", "on", "")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if settings.GridSize < 4 || settings.GridSize > 6 {
+			t.Fatalf("Expected a random grid size between 4 and 6, but got %d", settings.GridSize)
+		}
+	}
+}
+
+func TestLimitsValidate(t *testing.T) {
+	if err := DefaultLimits().Validate(); err != nil {
+		t.Errorf("Expected the default limits to be valid, but got %v", err)
+	}
+
+	invalidLimits := []Limits{
+		{MinGridSize: 1, MaxGridSize: 8, MaxRandomGridSize: 6, MinMinesRatio: 0.1, MaxMinesRatio: 0.8},
+		{MinGridSize: 8, MaxGridSize: 4, MaxRandomGridSize: 6, MinMinesRatio: 0.1, MaxMinesRatio: 0.8},
+		{MinGridSize: 2, MaxGridSize: 8, MaxRandomGridSize: 10, MinMinesRatio: 0.1, MaxMinesRatio: 0.8},
+		{MinGridSize: 2, MaxGridSize: 8, MaxRandomGridSize: 6, MinMinesRatio: 0.9, MaxMinesRatio: 0.8},
+		{MinGridSize: 2, MaxGridSize: 8, MaxRandomGridSize: 6, MinMinesRatio: 0.0, MaxMinesRatio: 0.1},
+		{MinGridSize: 2, MaxGridSize: 8, MaxRandomGridSize: 6, MinMinesRatio: 0.1, MaxMinesRatio: 0.8, MaxUnfinishedGames: -1},
+	}
+	for _, limits := range invalidLimits {
+		if err := limits.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", limits)
+		}
+	}
+}
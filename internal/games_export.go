@@ -0,0 +1,305 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"minesweeper/internal/db"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// exportBatchSize is how many games the exports load from the database at once.
+	exportBatchSize = 500
+	// exportBatchWriteTimeout is how long every batch has to be written. The deadline is pushed back before each
+	// one, so that the server's WriteTimeout doesn't cut a large export short.
+	exportBatchWriteTimeout = 30 * time.Second
+)
+
+// exportContentTypes are the formats of the exports by their format parameter, csv is the default.
+var exportContentTypes = map[string]string{
+	"csv":    "text/csv; charset=utf-8",
+	"ndjson": "application/x-ndjson",
+}
+
+// exportColumns is the header of the CSV exports, the NDJSON exports use the same names as keys.
+var exportColumns = []string{
+	"id", "uuid", "grid_size", "mines_amount", "game_failed", "game_won", "grid_state", "created_at",
+	"win_rule", "session_id", "abandoned_at", "moves", "status", "duration_seconds", "density",
+}
+
+// gameExport is a game as exported: its columns, the amount of its moves and the fields derived from them.
+// Missing values are null in NDJSON and empty in CSV.
+type gameExport struct {
+	Id          int64      `json:"id"`
+	Uuid        string     `json:"uuid"`
+	GridSize    int64      `json:"grid_size"`
+	MinesAmount int64      `json:"mines_amount"`
+	GameFailed  bool       `json:"game_failed"`
+	GameWon     bool       `json:"game_won"`
+	GridState   *string    `json:"grid_state"`
+	CreatedAt   *time.Time `json:"created_at"`
+	WinRule     string     `json:"win_rule"`
+	SessionId   *string    `json:"session_id"`
+	AbandonedAt *time.Time `json:"abandoned_at"`
+	Moves       int64      `json:"moves"`
+	Status      string     `json:"status"`
+	// DurationSeconds runs from the creation of the game to its last move, or to when it was abandoned.
+	DurationSeconds *int64 `json:"duration_seconds"`
+	// Density is the share of the cells that are mines.
+	Density float64 `json:"density"`
+}
+
+func gameExportOf(row db.ExportFilteredGamesRow) gameExport {
+	export := gameExport{
+		Id:          row.Id,
+		Uuid:        row.Uuid,
+		GridSize:    row.GridSize,
+		MinesAmount: row.MinesAmount,
+		GameFailed:  row.GameFailed,
+		GameWon:     row.GameWon,
+		GridState:   &row.GridState,
+		WinRule:     row.WinRule,
+		Moves:       row.MovesCount,
+		Status:      GameStatus(row.GameWon, row.GameFailed, row.AbandonedAt),
+	}
+	if row.CreatedAt.Valid {
+		createdAt := row.CreatedAt.Time.UTC()
+		export.CreatedAt = &createdAt
+	}
+	if row.SessionId.Valid {
+		export.SessionId = &row.SessionId.String
+	}
+	if row.AbandonedAt.Valid {
+		abandonedAt := row.AbandonedAt.Time.UTC()
+		export.AbandonedAt = &abandonedAt
+	}
+	end := row.LastMoveAt
+	if row.AbandonedAt.Valid {
+		end = row.AbandonedAt
+	}
+	if end.Valid && row.CreatedAt.Valid {
+		duration := int64(end.Time.Sub(row.CreatedAt.Time).Round(time.Second) / time.Second)
+		export.DurationSeconds = &duration
+	}
+	if row.GridSize > 0 {
+		export.Density = float64(row.MinesAmount) / float64(row.GridSize*row.GridSize)
+	}
+	return export
+}
+
+// record is the CSV row of the game, in the order of exportColumns.
+func (g gameExport) record() []string {
+	optional := func(value *string) string {
+		if value == nil {
+			return ""
+		}
+		return *value
+	}
+	timestamp := func(value *time.Time) string {
+		if value == nil {
+			return ""
+		}
+		return value.Format(time.RFC3339)
+	}
+	var duration string
+	if g.DurationSeconds != nil {
+		duration = strconv.FormatInt(*g.DurationSeconds, 10)
+	}
+
+	return []string{
+		strconv.FormatInt(g.Id, 10),
+		g.Uuid,
+		strconv.FormatInt(g.GridSize, 10),
+		strconv.FormatInt(g.MinesAmount, 10),
+		strconv.FormatBool(g.GameFailed),
+		strconv.FormatBool(g.GameWon),
+		optional(g.GridState),
+		timestamp(g.CreatedAt),
+		g.WinRule,
+		optional(g.SessionId),
+		timestamp(g.AbandonedAt),
+		strconv.FormatInt(g.Moves, 10),
+		g.Status,
+		duration,
+		strconv.FormatFloat(g.Density, 'f', -1, 64),
+	}
+}
+
+// gamesEncoder writes the exported games in one of the exportContentTypes.
+type gamesEncoder interface {
+	encode(game gameExport) error
+	// flush writes out what is buffered, it is called after every batch.
+	flush() error
+}
+
+type csvGamesEncoder struct {
+	writer *csv.Writer
+}
+
+func newCSVGamesEncoder(w io.Writer) (*csvGamesEncoder, error) {
+	encoder := &csvGamesEncoder{writer: csv.NewWriter(w)}
+	return encoder, encoder.writer.Write(exportColumns)
+}
+
+func (e *csvGamesEncoder) encode(game gameExport) error {
+	return e.writer.Write(game.record())
+}
+
+func (e *csvGamesEncoder) flush() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+type ndjsonGamesEncoder struct {
+	encoder *json.Encoder
+}
+
+func (e *ndjsonGamesEncoder) encode(game gameExport) error {
+	return e.encoder.Encode(game)
+}
+
+func (e *ndjsonGamesEncoder) flush() error {
+	return nil
+}
+
+// ExportGames handles GET /games/export. It exports every game of the admin list that matches the filters
+// of its query string, see ParseGamesFilter, as CSV or NDJSON by the format parameter. Like the list, it
+// redacts the uuids for non-admins and leaves out the boards and the sessions.
+func (h *Handler) ExportGames(w http.ResponseWriter, r *http.Request) {
+	filter, err := ParseGamesFilter(r.URL.Query())
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+
+	isAdmin := IsAdmin(r.Context())
+	if err := checkUuidSearch(filter, isAdmin); err != nil {
+		h.error(w, r, err)
+		return
+	}
+
+	h.exportGames(w, r, "games", func(ctx context.Context, afterId int64) ([]db.ExportFilteredGamesRow, error) {
+		return h.Queries.ExportFilteredGames(ctx, filter.exportParams(afterId, exportBatchSize))
+	}, func(game *gameExport) {
+		if !isAdmin {
+			game.Uuid = RedactUuid(game.Uuid)
+			game.GridState = nil
+			game.SessionId = nil
+		}
+	})
+}
+
+// ExportSessionGames handles GET /session-games-export. It exports the games kept in the session cookie of the
+// player, the same games its stats are about, filtered like ExportGames. The boards of the games still in
+// progress are left out, they show the mines.
+func (h *Handler) ExportSessionGames(w http.ResponseWriter, r *http.Request) {
+	filter, err := ParseGamesFilter(r.URL.Query())
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+
+	// without games in the session there is nothing to export, rather than every game
+	storedUuids, sessionErr := GetGameFromSession(r, h.Store)
+	var uuids sql.NullString
+	if len(storedUuids) > 0 && sessionErr == nil {
+		encoded, err := json.Marshal(storedUuids)
+		if err != nil {
+			h.error(w, r, fmt.Errorf("failed to encode the games of the session: %w", err))
+			return
+		}
+		uuids = sql.NullString{String: string(encoded), Valid: true}
+	}
+
+	h.exportGames(w, r, "session-games", func(ctx context.Context, afterId int64) ([]db.ExportFilteredGamesRow, error) {
+		if !uuids.Valid {
+			return nil, nil
+		}
+		params := filter.exportParams(afterId, exportBatchSize)
+		params.Uuids = uuids
+		return h.Queries.ExportFilteredGames(ctx, params)
+	}, func(game *gameExport) {
+		if game.Status == "in_progress" {
+			game.GridState = nil
+		}
+	})
+}
+
+// exportGames streams the games returned by load, batch after batch, as an attachment named filename, with a
+// new write deadline for every batch.
+// The first batch is loaded before anything is written, so that a failing query still gets an error page;
+// a later failure can only cut the export short.
+func (h *Handler) exportGames(
+	w http.ResponseWriter,
+	r *http.Request,
+	filename string,
+	load func(ctx context.Context, afterId int64) ([]db.ExportFilteredGamesRow, error),
+	redact func(game *gameExport),
+) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		h.error(w, r, Unprocessable("The format must be csv or ndjson.", fmt.Errorf("invalid export format %q", format)))
+		return
+	}
+
+	games, err := load(r.Context(), 0)
+	if err != nil {
+		h.error(w, r, fmt.Errorf("failed to export games: %w", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+"."+format))
+
+	var encoder gamesEncoder = &ndjsonGamesEncoder{encoder: json.NewEncoder(w)}
+	if format == "csv" {
+		if encoder, err = newCSVGamesEncoder(w); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to export games", "error", err)
+			return
+		}
+	}
+	controller := http.NewResponseController(w)
+
+	for {
+		if err := controller.SetWriteDeadline(time.Now().Add(exportBatchWriteTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			slog.ErrorContext(r.Context(), "Failed to export games", "error", err)
+			return
+		}
+		for _, row := range games {
+			game := gameExportOf(row)
+			redact(&game)
+			if err := encoder.encode(game); err != nil {
+				slog.ErrorContext(r.Context(), "Failed to export games", "error", err)
+				return
+			}
+		}
+		if err := encoder.flush(); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to export games", "error", err)
+			return
+		}
+		if err := controller.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			slog.ErrorContext(r.Context(), "Failed to export games", "error", err)
+			return
+		}
+
+		if len(games) < exportBatchSize {
+			return
+		}
+		if games, err = load(r.Context(), games[len(games)-1].Id); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to export games", "error", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"minesweeper/internal/db"
+	"minesweeper/internal/game"
+	"minesweeper/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportGames(t *testing.T) {
+	h, service := newAdminTestHandler(t)
+	ctx := context.Background()
+
+	small, err := service.Create(ctx, game.Settings{GridSize: 5, MinesAmount: 5, WinRule: models.WinRuleClassic}, "session-1")
+	if err != nil {
+		t.Fatalf("Failed to create game: %v", err)
+	}
+	if _, err := service.Act(ctx, small.Uuid, game.ActionFlagCell, 0, 0); err != nil {
+		t.Fatalf("Failed to act: %v", err)
+	}
+	large, err := service.Create(ctx, game.Settings{GridSize: 10, MinesAmount: 10, WinRule: models.WinRuleClassic}, "")
+	if err != nil {
+		t.Fatalf("Failed to create game: %v", err)
+	}
+	if _, err := service.Abandon(ctx, large.Uuid); err != nil {
+		t.Fatalf("Failed to abandon game: %v", err)
+	}
+
+	recorder := serve(h, adminRequest(http.MethodGet, "/games/export?max_grid_size=5"))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, but got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if recorder.Header().Get("Content-Type") != "text/csv; charset=utf-8" || recorder.Header().Get("Content-Disposition") != `attachment; filename="games.csv"` {
+		t.Errorf("Expected a CSV attachment, but got %s (%s)", recorder.Header().Get("Content-Type"), recorder.Header().Get("Content-Disposition"))
+	}
+	records, err := csv.NewReader(recorder.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read the CSV export: %v", err)
+	}
+	if len(records) != 2 || strings.Join(records[0], ",") != strings.Join(exportColumns, ",") {
+		t.Fatalf("Expected the header and the small game, but got %v", records)
+	}
+	row := map[string]string{}
+	for i, column := range records[0] {
+		row[column] = records[1][i]
+	}
+	expected := map[string]string{
+		"uuid": small.Uuid, "grid_size": "5", "mines_amount": "5", "session_id": "session-1", "abandoned_at": "",
+		"moves": "1", "status": "in_progress", "density": "0.2",
+	}
+	for column, value := range expected {
+		if row[column] != value {
+			t.Errorf("Expected %s to be %q, but got %q", column, value, row[column])
+		}
+	}
+	if row["grid_state"] == "" || row["created_at"] == "" || row["duration_seconds"] == "" {
+		t.Errorf("Expected the grid state, the creation and the duration to be exported, but got %v", row)
+	}
+
+	recorder = serve(h, adminRequest(http.MethodGet, "/games/export?format=ndjson&status=abandoned"))
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("Expected an NDJSON export, but got %d (%s)", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+	var exported []gameExport
+	scanner := bufio.NewScanner(recorder.Body)
+	for scanner.Scan() {
+		var line gameExport
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("Failed to decode %q: %v", scanner.Text(), err)
+		}
+		exported = append(exported, line)
+	}
+	if len(exported) != 1 || exported[0].Uuid != large.Uuid || exported[0].Status != "abandoned" {
+		t.Fatalf("Expected only the abandoned game, but got %+v", exported)
+	}
+	if exported[0].AbandonedAt == nil || exported[0].DurationSeconds == nil || exported[0].SessionId != nil || exported[0].Density != 0.1 {
+		t.Errorf("Expected the abandoned game without a session, but got %+v", exported[0])
+	}
+
+	if recorder := serve(h, adminRequest(http.MethodGet, "/games/export?format=xlsx")); recorder.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422 for an unknown format, but got %d", recorder.Code)
+	}
+	if recorder := serve(h, adminRequest(http.MethodGet, "/games/export?status=paused")); recorder.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422 for an invalid filter, but got %d", recorder.Code)
+	}
+
+	// without credentials the export is redacted like the list
+	h.Admin = AdminConfig{Insecure: true}
+	recorder = serve(h, httptest.NewRequest(http.MethodGet, "/games/export", nil))
+	body := recorder.Body.String()
+	if recorder.Code != http.StatusOK || strings.Contains(body, small.Uuid) || strings.Contains(body, "session-1") || !strings.Contains(body, RedactUuid(small.Uuid)) {
+		t.Errorf("Expected the export to be redacted for non-admins, but got %d: %s", recorder.Code, body)
+	}
+	if recorder := serve(h, httptest.NewRequest(http.MethodGet, "/games/export?uuid="+small.Uuid, nil)); recorder.Code != http.StatusForbidden {
+		t.Errorf("Expected searching by a whole uuid to be forbidden to non-admins, but got %d", recorder.Code)
+	}
+}
+
+func TestExportSessionGames(t *testing.T) {
+	h, service := newAdminTestHandler(t)
+	ctx := context.Background()
+
+	own, err := service.Create(ctx, game.Settings{GridSize: 5, MinesAmount: 3, WinRule: models.WinRuleClassic}, "")
+	if err != nil {
+		t.Fatalf("Failed to create game: %v", err)
+	}
+	// a game that is not in the session cookie
+	other, err := service.Create(ctx, game.Settings{GridSize: 5, MinesAmount: 3, WinRule: models.WinRuleClassic}, "")
+	if err != nil {
+		t.Fatalf("Failed to create game: %v", err)
+	}
+	cookie := sessionCookie(t, h, own)
+
+	request := httptest.NewRequest(http.MethodGet, "/session-games-export?format=ndjson", nil)
+	request.AddCookie(cookie)
+	recorder := serve(h, request)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, but got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var exported gameExport
+	if err := json.Unmarshal(recorder.Body.Bytes(), &exported); err != nil {
+		t.Fatalf("Failed to decode %q: %v", recorder.Body.String(), err)
+	}
+	if exported.Uuid != own.Uuid || strings.Contains(recorder.Body.String(), other.Uuid) {
+		t.Errorf("Expected only the game of the session, but got %s", recorder.Body.String())
+	}
+	if exported.GridState != nil {
+		t.Errorf("Expected the board of the game in progress to be left out, but got %q", *exported.GridState)
+	}
+
+	// without a session nothing is exported, not even the games that have no session
+	recorder = serve(h, httptest.NewRequest(http.MethodGet, "/session-games-export", nil))
+	if recorder.Code != http.StatusOK || strings.TrimSpace(recorder.Body.String()) != strings.Join(exportColumns, ",") {
+		t.Errorf("Expected an empty export, but got %d: %s", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestExportGamesInBatches(t *testing.T) {
+	h, service := newAdminTestHandler(t)
+	ctx := context.Background()
+
+	games := 2*exportBatchSize + 1
+	for i := 0; i < games; i++ {
+		if _, err := service.Create(ctx, game.Settings{GridSize: 3, MinesAmount: 1, WinRule: models.WinRuleClassic}, ""); err != nil {
+			t.Fatalf("Failed to create game: %v", err)
+		}
+	}
+
+	// a real server, so that the write deadlines are set on the connection. Its WriteTimeout is over before the
+	// first batch is written, only the deadline pushed back for every batch lets the export through.
+	server := httptest.NewUnstartedServer(Routes(h, NewApiHandler(h.Templates, h.Store, h.Queries)))
+	server.Config.WriteTimeout = time.Nanosecond
+	server.Start()
+	defer server.Close()
+
+	request, err := http.NewRequest(http.MethodGet, server.URL+"/games/export?format=ndjson", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	request.SetBasicAuth("admin", "secret")
+	response, err := server.Client().Do(request)
+	if err != nil {
+		t.Fatalf("Failed to export games: %v", err)
+	}
+	defer response.Body.Close()
+
+	var ids []int64
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		var line gameExport
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("Failed to decode %q: %v", scanner.Text(), err)
+		}
+		ids = append(ids, line.Id)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("Failed to read the export: %v", err)
+	}
+	if len(ids) != games {
+		t.Fatalf("Expected all %d games over 3 batches, but got %d", games, len(ids))
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] <= ids[i-1] {
+			t.Fatalf("Expected the games ordered by id without repeats, but got %d after %d", ids[i], ids[i-1])
+		}
+	}
+}
+
+func TestGameExportDuration(t *testing.T) {
+	createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) sql.NullTime {
+		return sql.NullTime{Time: createdAt.Add(d), Valid: true}
+	}
+
+	testCases := []struct {
+		name        string
+		lastMoveAt  sql.NullTime
+		abandonedAt sql.NullTime
+		expected    string
+	}{
+		{"Without moves", sql.NullTime{}, sql.NullTime{}, ""},
+		{"Until the last move", at(90*time.Second + 400*time.Millisecond), sql.NullTime{}, "90"},
+		{"Until it was abandoned", at(90 * time.Second), at(time.Hour), "3600"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			export := gameExportOf(db.ExportFilteredGamesRow{
+				CreatedAt:   sql.NullTime{Time: createdAt, Valid: true},
+				LastMoveAt:  tc.lastMoveAt,
+				AbandonedAt: tc.abandonedAt,
+			})
+			// the duration column of the CSV export
+			if duration := export.record()[13]; duration != tc.expected {
+				t.Errorf("Expected the duration %q, but got %q", tc.expected, duration)
+			}
+		})
+	}
+}
@@ -0,0 +1,436 @@
+package internal
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"minesweeper/internal/db"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gameStatuses are the statuses the list of games can be filtered by, see GameStatus.
+var gameStatuses = []string{"won", "lost", "abandoned", "in_progress"}
+
+// gameSortColumns are the columns the list of games can be sorted by.
+var gameSortColumns = []string{"id", "uuid", "grid_size", "mines_amount", "status", "created_at"}
+
+// gamePageSizes are the page sizes offered on the list of games.
+var gamePageSizes = []int{10, 25, 50, 100}
+
+const (
+	defaultGamesSort     = "created_at"
+	defaultGamesPageSize = 25
+	// filterDateLayout is the format of the dates sent by <input type="date">.
+	filterDateLayout = "2006-01-02"
+)
+
+var uuidPrefixPattern = regexp.MustCompile(`^[0-9a-f-]{1,36}$`)
+
+// GamesFilter selects, sorts and pages the list of games of the admin area.
+// Zero values of the filters match every game.
+type GamesFilter struct {
+	Status      string
+	MinGridSize int
+	MaxGridSize int
+	MinMines    int
+	MaxMines    int
+	// CreatedFrom and CreatedTo are days, games created on both of them are included.
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	UuidPrefix  string
+	Sort        string
+	Descending  bool
+	// After and Before page through the list sorted by created_at, see GamesCursor.
+	// Page is then only shown to the user, the other sorts are paged by it with OFFSET.
+	After    GamesCursor
+	Before   GamesCursor
+	Page     int
+	PageSize int
+}
+
+// Keyset reports whether the list is paged by cursors instead of page numbers.
+func (f GamesFilter) Keyset() bool {
+	return f.Sort == "created_at"
+}
+
+// DefaultGamesFilter lists all games, the newest first.
+func DefaultGamesFilter() GamesFilter {
+	return GamesFilter{Sort: defaultGamesSort, Descending: true, Page: 1, PageSize: defaultGamesPageSize}
+}
+
+// ParseGamesFilter reads the filter from the query string of GET /games. Invalid values are reported
+// as 422 Unprocessable, except for the page number which falls back to the first page.
+func ParseGamesFilter(query url.Values) (GamesFilter, error) {
+	filter := DefaultGamesFilter()
+
+	if status := query.Get("status"); status != "" {
+		if !slices.Contains(gameStatuses, status) {
+			return filter, Unprocessable("The status must be won, lost, abandoned or in_progress.", fmt.Errorf("invalid status %q", status))
+		}
+		filter.Status = status
+	}
+
+	bounds := []struct {
+		key   string
+		name  string
+		value *int
+	}{
+		{"min_grid_size", "minimum grid size", &filter.MinGridSize},
+		{"max_grid_size", "maximum grid size", &filter.MaxGridSize},
+		{"min_mines", "minimum amount of mines", &filter.MinMines},
+		{"max_mines", "maximum amount of mines", &filter.MaxMines},
+	}
+	for _, bound := range bounds {
+		raw := query.Get(bound.key)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 0 {
+			return filter, Unprocessable(fmt.Sprintf("The %s must be a positive number.", bound.name), err)
+		}
+		*bound.value = value
+	}
+	if filter.MaxGridSize != 0 && filter.MinGridSize > filter.MaxGridSize {
+		return filter, Unprocessable("The minimum grid size can't be above the maximum.", nil)
+	}
+	if filter.MaxMines != 0 && filter.MinMines > filter.MaxMines {
+		return filter, Unprocessable("The minimum amount of mines can't be above the maximum.", nil)
+	}
+
+	dates := []struct {
+		key   string
+		value *time.Time
+	}{
+		{"created_from", &filter.CreatedFrom},
+		{"created_to", &filter.CreatedTo},
+	}
+	for _, date := range dates {
+		raw := query.Get(date.key)
+		if raw == "" {
+			continue
+		}
+		value, err := time.Parse(filterDateLayout, raw)
+		if err != nil {
+			return filter, Unprocessable("Dates must be formatted as YYYY-MM-DD.", err)
+		}
+		*date.value = value
+	}
+	if !filter.CreatedTo.IsZero() && filter.CreatedFrom.After(filter.CreatedTo) {
+		return filter, Unprocessable("The start date can't be after the end date.", nil)
+	}
+
+	if prefix := query.Get("uuid"); prefix != "" {
+		if !uuidPrefixPattern.MatchString(prefix) {
+			return filter, Unprocessable("A UUID only contains lowercase hexadecimal digits and dashes.", fmt.Errorf("invalid uuid prefix %q", prefix))
+		}
+		filter.UuidPrefix = prefix
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		if !slices.Contains(gameSortColumns, sort) {
+			return filter, Unprocessable("Games can't be sorted by this column.", fmt.Errorf("invalid sort column %q", sort))
+		}
+		filter.Sort = sort
+	}
+	switch order := query.Get("order"); order {
+	case "", "desc":
+		filter.Descending = true
+	case "asc":
+		filter.Descending = false
+	default:
+		return filter, Unprocessable("The order must be asc or desc.", fmt.Errorf("invalid order %q", order))
+	}
+
+	cursors := []struct {
+		key   string
+		value *GamesCursor
+	}{
+		{"after", &filter.After},
+		{"before", &filter.Before},
+	}
+	for _, cursor := range cursors {
+		raw := query.Get(cursor.key)
+		if raw == "" {
+			continue
+		}
+		value, err := ParseGamesCursor(raw)
+		if err != nil {
+			return filter, Unprocessable("The link to this page is broken, go back to the first page.", err)
+		}
+		*cursor.value = value
+	}
+	if !filter.After.IsZero() && !filter.Before.IsZero() {
+		return filter, Unprocessable("The link to this page is broken, go back to the first page.", errors.New("both after and before cursors"))
+	}
+	if !filter.Keyset() && (!filter.After.IsZero() || !filter.Before.IsZero()) {
+		return filter, Unprocessable("Only the list sorted by creation date is paged by cursors.", fmt.Errorf("cursor with sort %q", filter.Sort))
+	}
+
+	if page, err := strconv.Atoi(query.Get("page")); err == nil && page > 1 {
+		filter.Page = page
+	}
+
+	if raw := query.Get("page_size"); raw != "" {
+		pageSize, err := strconv.Atoi(raw)
+		if err != nil || !slices.Contains(gamePageSizes, pageSize) {
+			return filter, Unprocessable(fmt.Sprintf("The page size must be one of %v.", gamePageSizes), err)
+		}
+		filter.PageSize = pageSize
+	}
+
+	return filter, nil
+}
+
+// Values encodes the filter as a query string, leaving out the defaults.
+func (f GamesFilter) Values() url.Values {
+	values := url.Values{}
+	if f.Status != "" {
+		values.Set("status", f.Status)
+	}
+	for key, value := range map[string]int{
+		"min_grid_size": f.MinGridSize,
+		"max_grid_size": f.MaxGridSize,
+		"min_mines":     f.MinMines,
+		"max_mines":     f.MaxMines,
+	} {
+		if value != 0 {
+			values.Set(key, strconv.Itoa(value))
+		}
+	}
+	if date := f.CreatedFromDate(); date != "" {
+		values.Set("created_from", date)
+	}
+	if date := f.CreatedToDate(); date != "" {
+		values.Set("created_to", date)
+	}
+	if f.UuidPrefix != "" {
+		values.Set("uuid", f.UuidPrefix)
+	}
+	if f.Sort != defaultGamesSort || !f.Descending {
+		values.Set("sort", f.Sort)
+		values.Set("order", f.order())
+	}
+	if !f.After.IsZero() {
+		values.Set("after", f.After.String())
+	}
+	if !f.Before.IsZero() {
+		values.Set("before", f.Before.String())
+	}
+	if f.Page > 1 {
+		values.Set("page", strconv.Itoa(f.Page))
+	}
+	if f.PageSize != defaultGamesPageSize {
+		values.Set("page_size", strconv.Itoa(f.PageSize))
+	}
+	return values
+}
+
+func (f GamesFilter) url() string {
+	if query := f.Values().Encode(); query != "" {
+		return "/games?" + query
+	}
+	return "/games"
+}
+
+// nextPage continues the list sorted by created_at right after the last game of the current page.
+func (f GamesFilter) nextPage(last GamesCursor) GamesFilter {
+	f.After, f.Before = last, GamesCursor{}
+	f.Page++
+	return f
+}
+
+// prevPage continues the list sorted by created_at right before the first game of the current page.
+// The first page is loaded without a cursor, so it also shows the games created since.
+func (f GamesFilter) prevPage(first GamesCursor) GamesFilter {
+	if f.Page <= 2 {
+		return f.firstPage()
+	}
+	f.After, f.Before = GamesCursor{}, first
+	f.Page--
+	return f
+}
+
+func (f GamesFilter) firstPage() GamesFilter {
+	f.After, f.Before = GamesCursor{}, GamesCursor{}
+	f.Page = 1
+	return f
+}
+
+// SortURL links to the first page of the list sorted by column. Sorting by the current column
+// again flips the order.
+func (f GamesFilter) SortURL(column string) string {
+	f.Descending = f.Sort == column && !f.Descending
+	f.Sort = column
+	return f.firstPage().url()
+}
+
+// SortIndicator returns the arrow shown next to the heading of the sorted column.
+func (f GamesFilter) SortIndicator(column string) string {
+	switch {
+	case f.Sort != column:
+		return ""
+	case f.Descending:
+		return "▼"
+	default:
+		return "▲"
+	}
+}
+
+// ExportURL links to the export of every game matching the filter in format, see ExportGames.
+// The sort and the page don't change what is exported, so they are left out.
+func (f GamesFilter) ExportURL(format string) string {
+	defaults := DefaultGamesFilter()
+	f.Sort, f.Descending, f.PageSize = defaults.Sort, defaults.Descending, defaults.PageSize
+	values := f.firstPage().Values()
+	values.Set("format", format)
+	return "/games/export?" + values.Encode()
+}
+
+// CreatedFromDate and CreatedToDate format the dates for <input type="date">.
+func (f GamesFilter) CreatedFromDate() string {
+	return formatFilterDate(f.CreatedFrom)
+}
+
+func (f GamesFilter) CreatedToDate() string {
+	return formatFilterDate(f.CreatedTo)
+}
+
+func (f GamesFilter) PageSizes() []int {
+	return gamePageSizes
+}
+
+func (f GamesFilter) order() string {
+	if f.Descending {
+		return "desc"
+	}
+	return "asc"
+}
+
+func (f GamesFilter) countParams() db.CountFilteredGamesParams {
+	params := db.CountFilteredGamesParams{
+		Status:      sql.NullString{String: f.Status, Valid: f.Status != ""},
+		MinGridSize: nullPositive(f.MinGridSize),
+		MaxGridSize: nullPositive(f.MaxGridSize),
+		MinMines:    nullPositive(f.MinMines),
+		MaxMines:    nullPositive(f.MaxMines),
+		UuidPrefix:  sql.NullString{String: f.UuidPrefix, Valid: f.UuidPrefix != ""},
+	}
+	if !f.CreatedFrom.IsZero() {
+		params.CreatedFrom = sql.NullString{String: f.CreatedFrom.UTC().Format(sqlTimestampLayout), Valid: true}
+	}
+	if !f.CreatedTo.IsZero() {
+		// the whole last day is included
+		params.CreatedBefore = sql.NullString{String: f.CreatedTo.AddDate(0, 0, 1).UTC().Format(sqlTimestampLayout), Valid: true}
+	}
+	return params
+}
+
+func (f GamesFilter) listParams() db.ListFilteredGamesParams {
+	count := f.countParams()
+	return db.ListFilteredGamesParams{
+		Status:        count.Status,
+		MinGridSize:   count.MinGridSize,
+		MaxGridSize:   count.MaxGridSize,
+		MinMines:      count.MinMines,
+		MaxMines:      count.MaxMines,
+		CreatedFrom:   count.CreatedFrom,
+		CreatedBefore: count.CreatedBefore,
+		UuidPrefix:    count.UuidPrefix,
+		Sort:          f.Sort + "_" + f.order(),
+		PageSize:      int64(f.PageSize),
+		PageOffset:    int64((f.Page - 1) * f.PageSize),
+	}
+}
+
+// keysetParams returns the params of ListFilteredGames for a page right after cursor, towards the older games
+// or the newer ones. A zero cursor starts at the newest or the oldest game. One more game than fits on the page
+// is requested, to tell whether there are more.
+func (f GamesFilter) keysetParams(cursor GamesCursor, towardsOlder bool) db.ListFilteredGamesParams {
+	params := f.listParams()
+	params.Sort = "created_at_asc"
+	if towardsOlder {
+		params.Sort = "created_at_desc"
+	}
+	if !cursor.IsZero() {
+		params.CursorCreatedAt = sql.NullString{String: cursor.CreatedAt.UTC().Format(sqlTimestampLayout), Valid: true}
+		params.CursorId = sql.NullInt64{Int64: cursor.Id, Valid: true}
+	}
+	params.PageSize = int64(f.PageSize + 1)
+	params.PageOffset = 0
+	return params
+}
+
+// exportParams returns the params of ExportFilteredGames for the batch of games right after afterId.
+// The exports ignore the sort and the page of the filter, they go through every matching game by id.
+func (f GamesFilter) exportParams(afterId int64, batchSize int) db.ExportFilteredGamesParams {
+	count := f.countParams()
+	return db.ExportFilteredGamesParams{
+		Status:        count.Status,
+		MinGridSize:   count.MinGridSize,
+		MaxGridSize:   count.MaxGridSize,
+		MinMines:      count.MinMines,
+		MaxMines:      count.MaxMines,
+		CreatedFrom:   count.CreatedFrom,
+		CreatedBefore: count.CreatedBefore,
+		UuidPrefix:    count.UuidPrefix,
+		AfterId:       afterId,
+		PageSize:      int64(batchSize),
+	}
+}
+
+// GamesCursor points at a game of the list sorted by created_at, the next or previous page starts right
+// next to it. Unlike with an OFFSET, games created meanwhile don't shift the pages.
+type GamesCursor struct {
+	CreatedAt time.Time
+	Id        int64
+}
+
+// sqlTimestampLayout is the format SQLite stores CURRENT_TIMESTAMP in, SQLite compares the creation bounds
+// and the cursor to created_at as text. PostgreSQL keeps the microseconds.
+const sqlTimestampLayout = "2006-01-02 15:04:05.999999"
+
+func gamesCursorOf(game db.ListFilteredGamesRow) GamesCursor {
+	return GamesCursor{CreatedAt: game.CreatedAt.Time, Id: game.Id}
+}
+
+func (c GamesCursor) IsZero() bool {
+	return c == GamesCursor{}
+}
+
+// String encodes the cursor for the query string, as the creation time and the id of the game.
+func (c GamesCursor) String() string {
+	return c.CreatedAt.UTC().Format(time.RFC3339Nano) + "_" + strconv.FormatInt(c.Id, 10)
+}
+
+func ParseGamesCursor(value string) (GamesCursor, error) {
+	rawCreatedAt, rawId, ok := strings.Cut(value, "_")
+	if !ok {
+		return GamesCursor{}, fmt.Errorf("invalid cursor %q", value)
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, rawCreatedAt)
+	if err != nil {
+		return GamesCursor{}, fmt.Errorf("invalid cursor %q: %w", value, err)
+	}
+	id, err := strconv.ParseInt(rawId, 10, 64)
+	if err != nil || id < 1 {
+		return GamesCursor{}, fmt.Errorf("invalid cursor %q: id must be positive", value)
+	}
+	return GamesCursor{CreatedAt: createdAt.UTC(), Id: id}, nil
+}
+
+func nullPositive(value int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(value), Valid: value > 0}
+}
+
+func formatFilterDate(date time.Time) string {
+	if date.IsZero() {
+		return ""
+	}
+	return date.Format(filterDateLayout)
+}
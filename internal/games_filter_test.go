@@ -0,0 +1,322 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"minesweeper/internal/db"
+	"minesweeper/internal/storage"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestDatabase returns a migrated SQLite database in a temporary directory.
+func newTestDatabase(t *testing.T) *storage.Database {
+	t.Helper()
+
+	database, err := storage.Open(filepath.Join(t.TempDir(), "minesweeper.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	if _, _, err := database.Migrate(context.Background()); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+
+	return database
+}
+
+// newTestRepository returns the repository of a migrated SQLite database in a temporary directory.
+func newTestRepository(t *testing.T) storage.Repository {
+	t.Helper()
+
+	return newTestDatabase(t).Repository()
+}
+
+func TestParseGamesFilter(t *testing.T) {
+	query, _ := url.ParseQuery("status=lost&min_grid_size=5&max_grid_size=10&min_mines=2&created_from=2024-09-01&created_to=2024-09-30&uuid=0f8f&sort=mines_amount&order=asc&page=3&page_size=50")
+
+	filter, err := ParseGamesFilter(query)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := GamesFilter{
+		Status:      "lost",
+		MinGridSize: 5,
+		MaxGridSize: 10,
+		MinMines:    2,
+		CreatedFrom: time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC),
+		CreatedTo:   time.Date(2024, 9, 30, 0, 0, 0, 0, time.UTC),
+		UuidPrefix:  "0f8f",
+		Sort:        "mines_amount",
+		Descending:  false,
+		Page:        3,
+		PageSize:    50,
+	}
+	if filter != expected {
+		t.Errorf("Expected %+v, but got %+v", expected, filter)
+	}
+	if filter.Values().Encode() != query.Encode() {
+		t.Errorf("Expected the filter to encode back to %s, but got %s", query.Encode(), filter.Values().Encode())
+	}
+
+	params := filter.listParams()
+	if params.Sort != "mines_amount_asc" || params.PageOffset != 100 || params.CreatedBefore.String != "2024-10-01 00:00:00" {
+		t.Errorf("Unexpected query params %+v", params)
+	}
+
+	defaults, err := ParseGamesFilter(url.Values{})
+	if err != nil || defaults != DefaultGamesFilter() || defaults.url() != "/games" {
+		t.Errorf("Expected the default filter, but got %+v (%v)", defaults, err)
+	}
+
+	for _, invalid := range []string{
+		"status=paused",
+		"min_grid_size=-1",
+		"max_mines=many",
+		"min_grid_size=10&max_grid_size=5",
+		"created_from=01/09/2024",
+		"created_from=2024-09-30&created_to=2024-09-01",
+		"uuid=0F8F",
+		"uuid=%25",
+		"sort=grid_state",
+		"order=up",
+		"page_size=1000",
+	} {
+		query, _ := url.ParseQuery(invalid)
+		if _, err := ParseGamesFilter(query); toHTTPError(err).Status != http.StatusUnprocessableEntity {
+			t.Errorf("Expected %s to be unprocessable, but got %v", invalid, err)
+		}
+	}
+}
+
+func TestGamesFilterLinks(t *testing.T) {
+	filter := DefaultGamesFilter()
+	filter.Status = "won"
+	filter.Page = 2
+
+	if link := filter.nextPage(GamesCursor{CreatedAt: time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC), Id: 7}).url(); link != "/games?after=2024-09-01T12%3A00%3A00Z_7&page=3&status=won" {
+		t.Errorf("Expected the next page to keep the filters, but got %s", link)
+	}
+	if link := filter.prevPage(GamesCursor{CreatedAt: time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC), Id: 7}).url(); link != "/games?status=won" {
+		t.Errorf("Expected the second page to link back to the first page without a cursor, but got %s", link)
+	}
+	if link := filter.SortURL("grid_size"); link != "/games?order=asc&sort=grid_size&status=won" {
+		t.Errorf("Expected a new column to be sorted ascending from the first page, but got %s", link)
+	}
+	if link := filter.SortURL("created_at"); link != "/games?order=asc&sort=created_at&status=won" {
+		t.Errorf("Expected the sorted column to flip its order, but got %s", link)
+	}
+	filter.PageSize = 50
+	if link := filter.ExportURL("ndjson"); link != "/games/export?format=ndjson&status=won" {
+		t.Errorf("Expected the export to keep only the filters, but got %s", link)
+	}
+	if filter.SortIndicator("created_at") != "▼" || filter.SortIndicator("id") != "" {
+		t.Errorf("Expected only the sorted column to be marked")
+	}
+}
+
+func TestIndexGamesFilters(t *testing.T) {
+	h := newTestHandler(t, newFakeGameService())
+	h.Queries = newTestRepository(t)
+
+	ctx := context.Background()
+	var uuids []string
+	for _, gridSize := range []int64{5, 8, 12} {
+		created, err := h.Queries.CreateGame(ctx, db.CreateGameParams{GridSize: gridSize, MinesAmount: 3, GridState: "~state", WinRule: "classic"})
+		if err != nil {
+			t.Fatalf("Failed to create game: %v", err)
+		}
+		uuids = append(uuids, created.Uuid)
+	}
+
+	h.Admin = AdminConfig{Username: "admin", Password: "secret"}
+	request := httptest.NewRequest(http.MethodGet, "/games?min_grid_size=6&sort=grid_size&order=asc", nil)
+	request.SetBasicAuth("admin", "secret")
+	recorder := serve(h, request)
+
+	body := recorder.Body.String()
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, but got %d: %s", recorder.Code, body)
+	}
+	if strings.Contains(body, uuids[0]) || !strings.Contains(body, uuids[1]) || !strings.Contains(body, uuids[2]) {
+		t.Errorf("Expected only the games with a bigger grid to be listed")
+	}
+	if strings.Index(body, uuids[1]) > strings.Index(body, uuids[2]) {
+		t.Errorf("Expected the games to be sorted by their grid size")
+	}
+	if !strings.Contains(body, `href="/games?min_grid_size=6&amp;order=desc&amp;sort=grid_size"`) {
+		t.Errorf("Expected the sort link to keep the filters and flip the order")
+	}
+
+	recorder = serve(h, httptest.NewRequest(http.MethodGet, "/games?page_size=7", nil))
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the list to still require credentials, but got %d", recorder.Code)
+	}
+
+	// without credentials nobody is an admin, so searching by whole uuids is not allowed
+	h.Admin = AdminConfig{Insecure: true}
+	recorder = serve(h, httptest.NewRequest(http.MethodGet, "/games?uuid="+uuids[0], nil))
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("Expected a search by a whole uuid to be forbidden, but got %d", recorder.Code)
+	}
+
+	recorder = serve(h, httptest.NewRequest(http.MethodGet, "/games?uuid="+uuids[0][:redactedUuidLength], nil))
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), RedactUuid(uuids[0])) {
+		t.Errorf("Expected the game to be found by the redacted part of its uuid, but got %d", recorder.Code)
+	}
+	if strings.Contains(recorder.Body.String(), RedactUuid(uuids[1])) {
+		t.Errorf("Expected only the game with the prefix to be listed")
+	}
+}
+
+func TestIndexGamesCreatedBounds(t *testing.T) {
+	database := newTestDatabase(t)
+	h := newTestHandler(t, newFakeGameService())
+	h.Queries = database.Repository()
+	h.Admin = AdminConfig{Username: "admin", Password: "secret"}
+
+	ctx := context.Background()
+	var uuids []string
+	// created right at the start of the first day and right at the start of the day after the last one
+	for _, createdAt := range []string{"2024-09-01 00:00:00", "2024-09-03 00:00:00"} {
+		created, err := h.Queries.CreateGame(ctx, db.CreateGameParams{GridSize: 5, MinesAmount: 3, GridState: "~state", WinRule: "classic"})
+		if err != nil {
+			t.Fatalf("Failed to create game: %v", err)
+		}
+		if _, err := database.ExecContext(ctx, "UPDATE games SET created_at = ? WHERE id = ?", createdAt, created.Id); err != nil {
+			t.Fatalf("Failed to set the creation time: %v", err)
+		}
+		uuids = append(uuids, created.Uuid)
+	}
+
+	page := getGamesPage(t, h, "/games?created_from=2024-09-01&created_to=2024-09-02")
+	if fmt.Sprint(page.uuids) != fmt.Sprint(uuids[:1]) {
+		t.Errorf("Expected only the game created at the start of the range, but got %v", page.uuids)
+	}
+}
+
+var (
+	uuidPattern     = regexp.MustCompile(`\s[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\s`)
+	pagesPattern    = regexp.MustCompile(`page\s+(\d+)\s+of\s+(\d+)`)
+	prevLinkPattern = regexp.MustCompile(`href="([^"]*)"\s*class="[^"]*"\s*>\s*Previous`)
+	nextLinkPattern = regexp.MustCompile(`href="([^"]*)"\s*class="[^"]*"\s*>\s*Next`)
+)
+
+// gamesPage is what the list of games shows on one page.
+type gamesPage struct {
+	uuids    []string
+	pages    string
+	prevLink string
+	nextLink string
+}
+
+func getGamesPage(t *testing.T, h *Handler, target string) gamesPage {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodGet, target, nil)
+	request.SetBasicAuth("admin", "secret")
+	recorder := serve(h, request)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 for %s, but got %d: %s", target, recorder.Code, recorder.Body.String())
+	}
+
+	body := recorder.Body.String()
+	// only the listed uuids, not the links to their details
+	page := gamesPage{}
+	for _, uuid := range uuidPattern.FindAllString(body, -1) {
+		page.uuids = append(page.uuids, strings.TrimSpace(uuid))
+	}
+	if match := pagesPattern.FindStringSubmatch(body); match != nil {
+		page.pages = match[1] + "/" + match[2]
+	}
+	if match := prevLinkPattern.FindStringSubmatch(body); match != nil {
+		page.prevLink = html.UnescapeString(match[1])
+	}
+	if match := nextLinkPattern.FindStringSubmatch(body); match != nil {
+		page.nextLink = html.UnescapeString(match[1])
+	}
+	return page
+}
+
+func TestIndexGamesPagination(t *testing.T) {
+	h := newTestHandler(t, newFakeGameService())
+	h.Queries = newTestRepository(t)
+	h.Admin = AdminConfig{Username: "admin", Password: "secret"}
+
+	if page := getGamesPage(t, h, "/games"); page.pages != "1/1" || page.prevLink != "" || page.nextLink != "" {
+		t.Errorf("Expected a single empty page without links, but got %+v", page)
+	}
+
+	// the games are created within the same second, so the cursors have to tell them apart by id
+	ctx := context.Background()
+	var newestFirst []string
+	for i := 0; i < 23; i++ {
+		created, err := h.Queries.CreateGame(ctx, db.CreateGameParams{GridSize: 5, MinesAmount: 3, GridState: "~state", WinRule: "classic"})
+		if err != nil {
+			t.Fatalf("Failed to create game: %v", err)
+		}
+		newestFirst = append([]string{created.Uuid}, newestFirst...)
+	}
+
+	t.Run("Keyset", func(t *testing.T) {
+		var pages []gamesPage
+		for link := "/games?page_size=10"; link != ""; {
+			page := getGamesPage(t, h, link)
+			pages = append(pages, page)
+			link = page.nextLink
+			if len(pages) > 3 {
+				t.Fatalf("Expected 3 pages, but kept on getting next links")
+			}
+		}
+
+		var listed []string
+		for i, page := range pages {
+			if expected := fmt.Sprintf("%d/3", i+1); page.pages != expected {
+				t.Errorf("Expected page %s, but got %s", expected, page.pages)
+			}
+			listed = append(listed, page.uuids...)
+		}
+		if fmt.Sprint(listed) != fmt.Sprint(newestFirst) {
+			t.Errorf("Expected every game exactly once, newest first, but got %v", listed)
+		}
+		if pages[0].prevLink != "" || !strings.Contains(pages[1].nextLink, "after=") {
+			t.Errorf("Expected the pages to be linked by cursors, but got %+v", pages)
+		}
+
+		// a new game doesn't shift the pages behind a cursor
+		if _, err := h.Queries.CreateGame(ctx, db.CreateGameParams{GridSize: 5, MinesAmount: 3, GridState: "~state", WinRule: "classic"}); err != nil {
+			t.Fatalf("Failed to create game: %v", err)
+		}
+		last := getGamesPage(t, h, pages[1].nextLink)
+		if fmt.Sprint(last.uuids) != fmt.Sprint(pages[2].uuids) {
+			t.Errorf("Expected the last page to stay the same, but got %v", last.uuids)
+		}
+
+		middle := getGamesPage(t, h, last.prevLink)
+		if !strings.Contains(last.prevLink, "before=") || fmt.Sprint(middle.uuids) != fmt.Sprint(pages[1].uuids) {
+			t.Errorf("Expected to go back to the second page with %s, but got %v", last.prevLink, middle.uuids)
+		}
+		if middle.prevLink != "/games?page_size=10" {
+			t.Errorf("Expected the second page to link back to the first page, but got %s", middle.prevLink)
+		}
+	})
+
+	t.Run("Offset", func(t *testing.T) {
+		page := getGamesPage(t, h, "/games?sort=id&order=asc&page_size=10&page=3")
+		if page.pages != "3/3" || len(page.uuids) != 4 || page.nextLink != "" {
+			t.Errorf("Expected the partly filled last page, but got %+v", page)
+		}
+		if page.prevLink != "/games?order=asc&page=2&page_size=10&sort=id" {
+			t.Errorf("Expected a link to the second page, but got %s", page.prevLink)
+		}
+	})
+}
@@ -1,325 +1,425 @@
 package internal
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"html/template"
-	"log"
+	"log/slog"
 	"minesweeper/internal/db"
+	"minesweeper/internal/game"
 	"minesweeper/internal/models"
+	"minesweeper/internal/storage"
 	"net/http"
+	"slices"
 	"strconv"
 
 	"github.com/gorilla/sessions"
 )
 
+// GameService is the part of game.Service the handlers rely on, so they can be tested with a fake.
+type GameService interface {
+	Create(ctx context.Context, settings game.Settings, sessionID string) (*models.Game, error)
+	Clone(ctx context.Context, uuid string, sessionID string) (*models.Game, error)
+	Load(ctx context.Context, uuid string) (*models.Game, error)
+	Act(ctx context.Context, uuid string, action game.Action, row int, col int) (*models.Game, error)
+	Abandon(ctx context.Context, uuid string) (*models.Game, error)
+	Delete(ctx context.Context, uuid string) error
+	AbandonAll(ctx context.Context, uuids []string) (int, error)
+	DeleteAll(ctx context.Context, uuids []string) (int, error)
+	Stats(ctx context.Context, uuids []string) (game.Stats, error)
+}
+
+var _ GameService = (*game.Service)(nil)
+
 type Handler struct {
 	Templates *template.Template
 	Store     *sessions.CookieStore
-	Queries   *db.Queries
+	Queries   storage.Repository
+	Games     GameService
+	Limits    game.Limits
+	// Admin guards the admin area, see AdminAuth.
+	Admin AdminConfig
 }
 
-func NewHandler(templates *template.Template, store *sessions.CookieStore, queries *db.Queries) *Handler {
-	return &Handler{Templates: templates, Store: store, Queries: queries}
+func NewHandler(templates *template.Template, store *sessions.CookieStore, queries storage.Repository, games GameService, limits game.Limits, admin AdminConfig) *Handler {
+	return &Handler{Templates: templates, Store: store, Queries: queries, Games: games, Limits: limits, Admin: admin}
 }
 
 func (h *Handler) Index(w http.ResponseWriter, r *http.Request) {
-	gameUuid := r.URL.Query().Get("game_uuid")
+	h.renderIndex(w, r, r.URL.Query().Get("game_uuid"))
+}
 
+// renderIndex renders the home page, with a game uuid the page loads that game right away.
+func (h *Handler) renderIndex(w http.ResponseWriter, r *http.Request, gameUuid string) {
 	responseData := map[string]interface{}{
 		"HasGameUuid": gameUuid != "",
 		"GameUuid":    gameUuid,
+		"CSRFToken":   CSRFTokenFromContext(r.Context()),
+		"Limits":      h.Limits,
 	}
 
 	err := h.Templates.ExecuteTemplate(w, "index", responseData)
 
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error rendering template: %v", err), http.StatusInternalServerError)
+		h.error(w, r, fmt.Errorf("failed to render template: %w", err))
 		return
 	}
 }
 
+// LoadGame handles GET /games/{uuid}. HTMX gets the game layout, a browser opening the link gets the
+// home page which then loads the game.
 func (h *Handler) LoadGame(w http.ResponseWriter, r *http.Request) {
+	gameUuid := r.PathValue("uuid")
 
-	gameUuid := r.URL.Query().Get("game_uuid")
-	if gameUuid == "" {
-		http.Error(w, "Missing game-uuid parameter", http.StatusBadRequest)
-		return
-	}
-
-	dbGame, dbGameRetrieveErr := h.Queries.GetGameByUuid(r.Context(), gameUuid)
-	if dbGameRetrieveErr != nil {
-		http.Error(w, fmt.Sprintf("Not able to retrieve game with such uuid : %v", dbGameRetrieveErr), http.StatusConflict)
+	if r.Header.Get("HX-Request") != "true" {
+		h.renderIndex(w, r, gameUuid)
 		return
 	}
 
-	game, gameModelErr := models.FromDbGame(&dbGame)
-	if gameModelErr != nil {
-		http.Error(w, fmt.Sprintf("Error during game casting: %v", gameModelErr), http.StatusInternalServerError)
+	loadedGame, err := h.Games.Load(r.Context(), gameUuid)
+	if err != nil {
+		h.error(w, r, err)
 		return
 	}
 
-	if err := SaveGameToSession(w, r, game, h.Store); err != nil {
-		http.Error(w, fmt.Sprintf("Not able to save game to session: %v", err), http.StatusInternalServerError)
+	if err := SaveGameToSession(w, r, loadedGame, h.Store); err != nil {
+		h.error(w, r, err)
 		return
 	}
 
-	gameGridHtml, gridGenerationErr := GenerateGridHTML(h.Templates, game)
+	gameGridHtml, gridGenerationErr := GenerateGridHTML(h.Templates, loadedGame)
 	if gridGenerationErr != nil {
-		http.Error(w, fmt.Sprintf("Not able to generate game grid: %v", gridGenerationErr), http.StatusInternalServerError)
+		h.error(w, r, fmt.Errorf("failed to generate game grid: %w", gridGenerationErr))
 		return
 	}
 
 	responseData := struct {
 		GridSize     int
 		MinesAmount  int
+		WinRule      models.WinRule
 		GameGridHtml template.HTML
 	}{
-		GridSize:     int(dbGame.GridSize),
-		MinesAmount:  int(dbGame.MinesAmount),
+		GridSize:     loadedGame.GridSize,
+		MinesAmount:  loadedGame.MinesAmount,
+		WinRule:      loadedGame.WinRule,
 		GameGridHtml: template.HTML(gameGridHtml),
 	}
 
-	err := h.Templates.ExecuteTemplate(w, "game_layout", responseData)
+	err = h.Templates.ExecuteTemplate(w, "game_layout", responseData)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error rendering template: %v", err), http.StatusInternalServerError)
+		h.error(w, r, fmt.Errorf("failed to render template: %w", err))
 		return
 	}
 }
 
+// StartGame handles POST /games.
 func (h *Handler) StartGame(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
-		h.returnErrorResponse(ErrorResponseConfig{
-			ResponseWriter: w,
-			ErrorMessage:   err.Error(),
-			ShowCloseBtn:   true,
-		})
-		log.Printf("Error parsing form: %v", err)
+		h.error(w, r, BadRequest("The submitted form could not be read.", err))
 		return
 	}
 
-	gameSettings, formValidationErr := ValidateGameSettingsForm(
+	gameSettings, formValidationErr := h.Limits.ParseSettings(
 		r.FormValue("grid-size"),
 		r.FormValue("mines-amount"),
 		r.FormValue("random-mines"),
 		r.FormValue("random-grid-size"),
+		r.FormValue("win-rule"),
 	)
 
 	if formValidationErr != nil {
-		h.returnErrorResponse(ErrorResponseConfig{
-			ResponseWriter: w,
-			ErrorMessage:   formValidationErr.Error(),
-			ShowCloseBtn:   true,
-		})
-		log.Printf("Form validation error: %v", formValidationErr)
+		h.error(w, r, formValidationErr)
 		return
 	}
 
-	// TODO eliminate need for this double creation of game because how grid state is initialized
-	newGame := models.NewGame(gameSettings.GridSize, gameSettings.MinesAmount)
-	dbGame, dbGameErr := h.Queries.CreateGame(r.Context(), db.CreateGameParams{
-		GridSize:    int64(gameSettings.GridSize),
-		MinesAmount: int64(gameSettings.MinesAmount),
-		GridState:   models.EncodeGameGrid(newGame.Grid),
-	})
-
-	if dbGameErr != nil {
-		h.returnErrorResponse(ErrorResponseConfig{
-			ResponseWriter: w,
-			ErrorMessage:   fmt.Sprintf("Error creating game: %v", dbGameErr),
-			ShowCloseBtn:   false,
-		})
+	if err := h.checkUnfinishedGames(r); err != nil {
+		h.error(w, r, err)
 		return
 	}
 
-	game, gameModelErr := models.FromDbGame(&dbGame)
-
-	if gameModelErr != nil {
-		h.returnErrorResponse(ErrorResponseConfig{
-			ResponseWriter: w,
-			ErrorMessage:   fmt.Sprintf("Error creating game model: %v", gameModelErr),
-			ShowCloseBtn:   false,
-		})
+	newGame, err := h.Games.Create(r.Context(), gameSettings, SessionID(r, h.Store))
+	if err != nil {
+		h.error(w, r, err)
 		return
 	}
-
-	if err := SaveGameToSession(w, r, game, h.Store); err != nil {
-		h.returnErrorResponse(ErrorResponseConfig{
-			ResponseWriter: w,
-			ErrorMessage:   fmt.Sprintf("Error saving game to session: %v", err),
-			ShowCloseBtn:   false,
-		})
+	if err := h.saveNewGameToSession(w, r, newGame); err != nil {
+		h.error(w, r, err)
 		return
 	}
 
-	gameGridHtml, gridGenerationErr := GenerateGridHTML(h.Templates, game)
+	gameGridHtml, gridGenerationErr := GenerateGridHTML(h.Templates, newGame)
 	if gridGenerationErr != nil {
-		h.returnErrorResponse(ErrorResponseConfig{
-			ResponseWriter: w,
-			ErrorMessage:   fmt.Sprintf("Error generating grid HTML: %v", gridGenerationErr),
-			ShowCloseBtn:   false,
-		})
+		h.error(w, r, fmt.Errorf("failed to generate game grid: %w", gridGenerationErr))
 		return
 	}
 
 	responseData := struct {
 		GridSize     int
 		MinesAmount  int
+		WinRule      models.WinRule
 		GameGridHtml template.HTML
 	}{
-		GridSize:     int(dbGame.GridSize),
-		MinesAmount:  int(dbGame.MinesAmount),
+		GridSize:     newGame.GridSize,
+		MinesAmount:  newGame.MinesAmount,
+		WinRule:      newGame.WinRule,
 		GameGridHtml: template.HTML(gameGridHtml),
 	}
 
 	w.Header().Set("HX-Trigger", "gameStarted")
-	err := h.Templates.ExecuteTemplate(w, "game_layout", responseData)
+	err = h.Templates.ExecuteTemplate(w, "game_layout", responseData)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error rendering template: %v", err), http.StatusInternalServerError)
+		h.error(w, r, fmt.Errorf("failed to render template: %w", err))
 		return
 	}
 }
 
-func (h *Handler) HandleGridAction(w http.ResponseWriter, r *http.Request) {
-	action := r.URL.Query().Get("action")
-	row, rowParErr := strconv.Atoi(r.URL.Query().Get("row"))
-	col, colParErr := strconv.Atoi(r.URL.Query().Get("col"))
-
-	if action == "" || rowParErr != nil || colParErr != nil {
-		http.Error(w, "Unprocessable or missing request parameters.", http.StatusUnprocessableEntity)
-		return
+// saveNewGameToSession remembers a game that was just stored in the session of the player. It only runs once
+// the game is committed, so the cookie never lists a game that was rolled back. If the session can't be saved,
+// the game is deleted again rather than left behind where nobody can reach it.
+func (h *Handler) saveNewGameToSession(w http.ResponseWriter, r *http.Request, newGame *models.Game) error {
+	err := SaveGameToSession(w, r, newGame, h.Store)
+	if err == nil {
+		return nil
 	}
+	if deleteErr := h.Games.Delete(r.Context(), newGame.Uuid); deleteErr != nil {
+		slog.ErrorContext(r.Context(), "Failed to delete the game that could not be saved in the session", "uuid", newGame.Uuid, "error", deleteErr)
+	}
+	return err
+}
 
-	storedGamesUuids, err := GetGameFromSession(r, h.Store)
+var errTooManyUnfinishedGames = errors.New("too many unfinished games in session")
 
-	if err != nil || len(storedGamesUuids) == 0 {
-		log.Printf("Failed to get game from session: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to get game from session: %v", err), http.StatusInternalServerError)
-		return
+// checkUnfinishedGames rejects a new game while the session already has Limits.MaxUnfinishedGames
+// unfinished games.
+func (h *Handler) checkUnfinishedGames(r *http.Request) error {
+	if h.Limits.MaxUnfinishedGames == 0 {
+		return nil
 	}
-	lastGameUuid := storedGamesUuids[len(storedGamesUuids)-1]
 
-	dbGame, err := h.Queries.GetGameByUuid(r.Context(), lastGameUuid)
-	if err != nil {
-		log.Printf("Failed to get game from database: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to get game from database: %v", err), http.StatusInternalServerError)
-		return
+	storedUuids, sessionErr := GetGameFromSession(r, h.Store)
+	if len(storedUuids) == 0 || sessionErr != nil {
+		return nil
 	}
 
-	game, err := models.FromDbGame(&dbGame)
+	stats, err := h.Games.Stats(r.Context(), storedUuids)
 	if err != nil {
-		log.Printf("Failed to convert db game: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to convert db game: %v", err), http.StatusInternalServerError)
+		return err
+	}
+
+	if stats.NotFinishedGames >= h.Limits.MaxUnfinishedGames {
+		return Conflict(
+			fmt.Sprintf("You have %d unfinished games, finish one of them before starting a new one.", stats.NotFinishedGames),
+			errTooManyUnfinishedGames,
+		)
+	}
+	return nil
+}
+
+// HandleGridAction handles POST /games/{uuid}/moves, the action, row and col are sent as form values.
+func (h *Handler) HandleGridAction(w http.ResponseWriter, r *http.Request) {
+	gameUuid := r.PathValue("uuid")
+	action := r.FormValue("action")
+	row, rowParErr := strconv.Atoi(r.FormValue("row"))
+	col, colParErr := strconv.Atoi(r.FormValue("col"))
+
+	if action == "" || rowParErr != nil || colParErr != nil {
+		h.error(w, r, Unprocessable("Unprocessable or missing request parameters.", errors.Join(rowParErr, colParErr)))
 		return
 	}
 
-	switch action {
-	case "reveal_cell":
-		game.RevealCell(row, col)
-	case "flag_cell":
-		game.FlagCell(row, col)
-	default:
-		http.Error(w, "Invalid action.", http.StatusBadRequest)
+	// only games started or loaded in this session can be played, like before moves were addressed by uuid
+	storedGamesUuids, err := GetGameFromSession(r, h.Store)
+	if err != nil || !contains(storedGamesUuids, gameUuid) {
+		h.error(w, r, Conflict("This game is not in progress in your session, start a new game or load this one first.", err))
 		return
 	}
 
-	encodedGridState := models.EncodeGameGrid(game.Grid)
-	err = h.Queries.UpdateGameGridStateById(r.Context(), db.UpdateGameGridStateByIdParams{
-		GameFailed: game.GameFailed,
-		GameWon:    game.GameWon,
-		GridState:  encodedGridState,
-		Id:         game.Id,
-	})
+	playedGame, err := h.Games.Act(r.Context(), gameUuid, game.Action(action), row, col)
 	if err != nil {
-		log.Printf("Failed to update game state in database: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to update game state in database: %v", err), http.StatusInternalServerError)
+		h.error(w, r, err)
 		return
 	}
 
-	gameGridHtml, gridGenerationErr := GenerateGridHTML(h.Templates, game)
+	gameGridHtml, gridGenerationErr := GenerateGridHTML(h.Templates, playedGame)
 	if gridGenerationErr != nil {
-		http.Error(w, fmt.Sprintf("Error generating grid HTML: %v", gridGenerationErr), http.StatusInternalServerError)
+		h.error(w, r, fmt.Errorf("failed to generate game grid: %w", gridGenerationErr))
 		return
 	}
 
 	w.Write([]byte(gameGridHtml))
 }
 
+// IndexGames handles GET /games, the list of games of the admin area. It is filtered, sorted and
+// paged by the query string, see ParseGamesFilter.
 func (h *Handler) IndexGames(w http.ResponseWriter, r *http.Request) {
-
-	page := r.URL.Query().Get("page")
-	pageNumber, err := strconv.Atoi(page)
-	if err != nil || pageNumber < 1 {
-		pageNumber = 1
+	filter, err := ParseGamesFilter(r.URL.Query())
+	if err != nil {
+		h.error(w, r, err)
+		return
 	}
 
-	var pageSize int64 = 25
-
-	offset := (pageNumber - 1) * int(pageSize) // pageSize
-
-	games, err := h.Queries.ListGames(r.Context(), db.ListGamesParams{
-		Limit:  pageSize,
-		Offset: int64(offset),
-	})
+	isAdmin := IsAdmin(r.Context())
+	if err := checkUuidSearch(filter, isAdmin); err != nil {
+		h.error(w, r, err)
+		return
+	}
 
+	totalGamesCount, err := h.Queries.CountFilteredGames(r.Context(), filter.countParams())
 	if err != nil {
-		log.Printf("Failed to get games from database: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to get games from database: %v", err), http.StatusInternalServerError)
+		h.error(w, r, fmt.Errorf("failed to count games: %w", err))
 		return
 	}
 
-	totalGamesCount, totalGamesCountErr := GetTotalGamesCount(h.Queries)
-	if totalGamesCountErr != nil {
-		log.Printf("Failed to get total games count: %v", totalGamesCountErr)
-		http.Error(w, fmt.Sprintf("Failed to get total games count: %v", totalGamesCountErr), http.StatusInternalServerError)
+	games, prevPage, nextPage, err := h.listGames(r.Context(), filter, totalGamesCount)
+	if err != nil {
+		h.error(w, r, fmt.Errorf("failed to list games: %w", err))
 		return
 	}
 
-	totalPages := totalGamesCount / pageSize
+	if !isAdmin {
+		for i := range games {
+			games[i].Uuid = RedactUuid(games[i].Uuid)
+		}
+	}
+
+	// the last page is usually only partly filled, an empty list still has its one empty page
+	totalPages := max((totalGamesCount+int64(filter.PageSize)-1)/int64(filter.PageSize), 1)
+
+	// the status is derived like on the details page, abandoned games are failed too but not lost
+	items := make([]gamesListItem, len(games))
+	for i, game := range games {
+		items[i] = gamesListItem{ListFilteredGamesRow: game, Status: GameStatus(game.GameWon, game.GameFailed, game.AbandonedAt)}
+	}
 
 	data := struct {
-		Games           []db.ListGamesRow
+		Games           []gamesListItem
+		Filter          GamesFilter
+		PrevPageURL     string
+		NextPageURL     string
 		CurrentPage     int
 		TotalPages      int
 		TotalGamesCount int
+		IsAdmin         bool
+		CSRFToken       string
 	}{
-		Games:           games,
-		CurrentPage:     pageNumber,
+		Games:           items,
+		Filter:          filter,
+		CurrentPage:     filter.Page,
 		TotalPages:      int(totalPages),
 		TotalGamesCount: int(totalGamesCount),
+		IsAdmin:         isAdmin,
+		CSRFToken:       CSRFTokenFromContext(r.Context()),
+	}
+	if prevPage != nil {
+		data.PrevPageURL = prevPage.url()
+	}
+	if nextPage != nil {
+		data.NextPageURL = nextPage.url()
 	}
 
 	if err := h.Templates.ExecuteTemplate(w, "index_games_page", data); err != nil {
-		log.Printf("Failed to execute template: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to render template: %v", err), http.StatusInternalServerError)
+		h.error(w, r, fmt.Errorf("failed to render template: %w", err))
 		return
 	}
 }
 
-type ErrorResponseConfig struct {
-	ResponseWriter http.ResponseWriter
-	ErrorMessage   string
-	ShowCloseBtn   bool
+// gamesListItem is a game on the admin list with its status.
+type gamesListItem struct {
+	db.ListFilteredGamesRow
+	Status string
 }
 
-func (h *Handler) returnErrorResponse(config ErrorResponseConfig) {
-	if config.ErrorMessage == "" {
-		config.ErrorMessage = "An error occurred"
+// checkUuidSearch forbids non-admins to search by more than the redacted part of a uuid,
+// that would let anybody guess whole uuids one character at a time.
+func checkUuidSearch(filter GamesFilter, isAdmin bool) error {
+	if isAdmin || len(filter.UuidPrefix) <= redactedUuidLength {
+		return nil
 	}
+	return Forbidden(
+		fmt.Sprintf("Only admins can search by more than the first %d characters of a UUID.", redactedUuidLength),
+		errAdminUnauthorized,
+	)
+}
 
-	responseData := struct {
-		ErrorMessage string
-		ShowCloseBtn bool
-	}{
-		ErrorMessage: config.ErrorMessage,
-		ShowCloseBtn: config.ShowCloseBtn,
+// listGames loads the page of games selected by filter, together with the filters of the previous and
+// next pages, which are nil on the first and the last page.
+func (h *Handler) listGames(ctx context.Context, filter GamesFilter, totalGamesCount int64) ([]db.ListFilteredGamesRow, *GamesFilter, *GamesFilter, error) {
+	if filter.Keyset() {
+		return h.listGamesByCursor(ctx, filter)
 	}
 
-	config.ResponseWriter.WriteHeader(http.StatusBadRequest)
-	err := h.Templates.ExecuteTemplate(config.ResponseWriter, "error_message", responseData)
+	games, err := h.Queries.ListFilteredGames(ctx, filter.listParams())
 	if err != nil {
-		http.Error(config.ResponseWriter, fmt.Sprintf("Error rendering template: %v", err), http.StatusInternalServerError)
-		return
+		return nil, nil, nil, err
 	}
+
+	var prevPage, nextPage *GamesFilter
+	if filter.Page > 1 {
+		prev := filter
+		prev.Page--
+		prevPage = &prev
+	}
+	if int64(filter.Page*filter.PageSize) < totalGamesCount {
+		next := filter
+		next.Page++
+		nextPage = &next
+	}
+	return games, prevPage, nextPage, nil
+}
+
+// listGamesByCursor loads a page of the list sorted by created_at, right after filter.After or right
+// before filter.Before. Pages before the cursor are read backwards and flipped.
+func (h *Handler) listGamesByCursor(ctx context.Context, filter GamesFilter) ([]db.ListFilteredGamesRow, *GamesFilter, *GamesFilter, error) {
+	backwards := !filter.Before.IsZero()
+	towardsOlder := filter.Descending != backwards
+
+	cursor := filter.After
+	if backwards {
+		cursor = filter.Before
+	}
+
+	games, err := h.Queries.ListFilteredGames(ctx, filter.keysetParams(cursor, towardsOlder))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	more := len(games) > filter.PageSize
+	if more {
+		games = games[:filter.PageSize]
+	}
+	if backwards {
+		slices.Reverse(games)
+	}
+	if len(games) == 0 {
+		// the games around the cursor are gone, only the way back to the first page is left
+		if !filter.After.IsZero() || backwards {
+			first := filter.firstPage()
+			return games, &first, nil, nil
+		}
+		return games, nil, nil, nil
+	}
+
+	hasPrev, hasNext := !filter.After.IsZero(), more
+	if backwards {
+		hasPrev, hasNext = more, true
+	}
+
+	var prevPage, nextPage *GamesFilter
+	if hasPrev {
+		prev := filter.prevPage(gamesCursorOf(games[0]))
+		prevPage = &prev
+	}
+	if hasNext {
+		next := filter.nextPage(gamesCursorOf(games[len(games)-1]))
+		nextPage = &next
+	}
+	return games, prevPage, nextPage, nil
+}
+
+// error responds with the status code and safe message of err, see writeError.
+func (h *Handler) error(w http.ResponseWriter, r *http.Request, err error) {
+	writeError(w, r, h.Templates, err)
 }
 
 func (h *Handler) SessionGamesInfo(w http.ResponseWriter, r *http.Request) {
@@ -328,16 +428,15 @@ func (h *Handler) SessionGamesInfo(w http.ResponseWriter, r *http.Request) {
 	if len(storedUuids) == 0 || sessionErr != nil {
 		err := h.Templates.ExecuteTemplate(w, "session_games_info", nil)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Error rendering template: %v", err), http.StatusInternalServerError)
+			h.error(w, r, fmt.Errorf("failed to render template: %w", err))
 		}
 		return
 	}
 
-	gamesInSessionInfo, err := h.Queries.GetGamesInfoByUuids(r.Context(), storedUuids)
+	gamesInSessionInfo, err := h.Games.Stats(r.Context(), storedUuids)
 
 	if err != nil {
-		log.Printf("Failed to fetch game counts from database: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to fetch game counts: %v", err), http.StatusInternalServerError)
+		h.error(w, r, err)
 		return
 	}
 
@@ -349,15 +448,15 @@ func (h *Handler) SessionGamesInfo(w http.ResponseWriter, r *http.Request) {
 		NotFinishedGames int
 	}{
 		HasGames:         gamesInSessionInfo.TotalGames > 0,
-		TotalGames:       int(gamesInSessionInfo.TotalGames),
-		LostGames:        int(gamesInSessionInfo.LostGames),
-		WonGames:         int(gamesInSessionInfo.WonGames),
-		NotFinishedGames: int(gamesInSessionInfo.NotFinishedGames),
+		TotalGames:       gamesInSessionInfo.TotalGames,
+		LostGames:        gamesInSessionInfo.LostGames,
+		WonGames:         gamesInSessionInfo.WonGames,
+		NotFinishedGames: gamesInSessionInfo.NotFinishedGames,
 	}
 
 	err = h.Templates.ExecuteTemplate(w, "session_games_info", responseData)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error rendering template: %v", err), http.StatusInternalServerError)
+		h.error(w, r, fmt.Errorf("failed to render template: %w", err))
 		return
 	}
 
@@ -367,7 +466,7 @@ func (h *Handler) Charts(w http.ResponseWriter, r *http.Request) {
 	err := h.Templates.ExecuteTemplate(w, "charts_page", nil)
 
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error rendering template: %v", err), http.StatusInternalServerError)
+		h.error(w, r, fmt.Errorf("failed to render template: %w", err))
 		return
 	}
 }
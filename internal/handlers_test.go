@@ -0,0 +1,440 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"minesweeper/internal/game"
+	"minesweeper/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/sessions"
+)
+
+// fakeGameService keeps games in memory and records how it was called.
+type fakeGameService struct {
+	games    map[string]*models.Game
+	created  []game.Settings
+	owners   []string
+	acted    []string
+	stats    game.Stats
+	loadErr  error
+	actErr   error
+	statsErr error
+}
+
+func newFakeGameService() *fakeGameService {
+	return &fakeGameService{games: map[string]*models.Game{}}
+}
+
+func (f *fakeGameService) Create(ctx context.Context, settings game.Settings, sessionID string) (*models.Game, error) {
+	newGame := models.NewGame(settings.GridSize, settings.MinesAmount, settings.WinRule)
+	f.store(newGame, sessionID)
+
+	f.created = append(f.created, settings)
+	return newGame, nil
+}
+
+func (f *fakeGameService) Clone(ctx context.Context, uuid string, sessionID string) (*models.Game, error) {
+	original, err := f.Load(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	clone := original.CloneBoard()
+	f.store(clone, sessionID)
+	return clone, nil
+}
+
+func (f *fakeGameService) store(newGame *models.Game, sessionID string) {
+	newGame.Id = int64(len(f.games) + 1)
+	newGame.Uuid = fmt.Sprintf("uuid-%d", newGame.Id)
+
+	f.owners = append(f.owners, sessionID)
+	f.games[newGame.Uuid] = newGame
+}
+
+func (f *fakeGameService) Load(ctx context.Context, uuid string) (*models.Game, error) {
+	if f.loadErr != nil {
+		return nil, f.loadErr
+	}
+
+	loadedGame, ok := f.games[uuid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", game.ErrGameNotFound, uuid)
+	}
+	return loadedGame, nil
+}
+
+func (f *fakeGameService) Act(ctx context.Context, uuid string, action game.Action, row int, col int) (*models.Game, error) {
+	f.acted = append(f.acted, fmt.Sprintf("%s %s %d,%d", uuid, action, row, col))
+
+	if f.actErr != nil {
+		return nil, f.actErr
+	}
+	return f.Load(ctx, uuid)
+}
+
+func (f *fakeGameService) Abandon(ctx context.Context, uuid string) (*models.Game, error) {
+	abandonedGame, err := f.Load(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+	if abandonedGame.GameFailed || abandonedGame.GameWon {
+		return nil, fmt.Errorf("%w: %s", game.ErrGameOver, uuid)
+	}
+
+	abandonedGame.GameFailed = true
+	return abandonedGame, nil
+}
+
+func (f *fakeGameService) Delete(ctx context.Context, uuid string) error {
+	if _, err := f.Load(ctx, uuid); err != nil {
+		return err
+	}
+
+	delete(f.games, uuid)
+	return nil
+}
+
+func (f *fakeGameService) AbandonAll(ctx context.Context, uuids []string) (int, error) {
+	var abandoned int
+	for _, uuid := range uuids {
+		if _, err := f.Abandon(ctx, uuid); err == nil {
+			abandoned++
+		}
+	}
+	return abandoned, nil
+}
+
+func (f *fakeGameService) DeleteAll(ctx context.Context, uuids []string) (int, error) {
+	var deleted int
+	for _, uuid := range uuids {
+		if err := f.Delete(ctx, uuid); err == nil {
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (f *fakeGameService) Stats(ctx context.Context, uuids []string) (game.Stats, error) {
+	return f.stats, f.statsErr
+}
+
+func newTestHandler(t *testing.T, games GameService) *Handler {
+	t.Helper()
+
+	funcMap := template.FuncMap{
+		"Sub": func(a int, b int) int { return a - b },
+		"Add": func(a int, b int) int { return a + b },
+	}
+
+	templates, err := template.New("").Funcs(funcMap).ParseGlob("../templates/*.html")
+	if err != nil {
+		t.Fatalf("Failed to parse templates: %v", err)
+	}
+	templates, err = templates.New("").Funcs(funcMap).ParseGlob("../templates/**/*.html")
+	if err != nil {
+		t.Fatalf("Failed to parse components: %v", err)
+	}
+
+	return NewHandler(templates, sessions.NewCookieStore([]byte("test-secret")), nil, games, game.DefaultLimits(), AdminConfig{})
+}
+
+// sessionCookie returns the cookie of a session remembering the given games.
+func sessionCookie(t *testing.T, h *Handler, games ...*models.Game) *http.Cookie {
+	t.Helper()
+
+	var cookie *http.Cookie
+	for _, sessionGame := range games {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		if cookie != nil {
+			request.AddCookie(cookie)
+		}
+
+		if err := SaveGameToSession(recorder, request, sessionGame, h.Store); err != nil {
+			t.Fatalf("Failed to save game to session: %v", err)
+		}
+		cookie = recorder.Result().Cookies()[0]
+	}
+
+	return cookie
+}
+
+func TestStartGame(t *testing.T) {
+	games := newFakeGameService()
+	h := newTestHandler(t, games)
+
+	form := url.Values{"grid-size": {"6"}, "mines-amount": {"5"}, "win-rule": {"strict"}}
+	request := httptest.NewRequest(http.MethodPost, "/start-game", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+
+	h.StartGame(recorder, request)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, but got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+	}
+	if recorder.Header().Get("HX-Trigger") != "gameStarted" {
+		t.Errorf("Expected the gameStarted event to be triggered")
+	}
+	if len(recorder.Result().Cookies()) == 0 {
+		t.Errorf("Expected the game to be saved in the session cookie")
+	}
+
+	expected := game.Settings{GridSize: 6, MinesAmount: 5, WinRule: models.WinRuleStrict}
+	if len(games.created) != 1 || games.created[0] != expected {
+		t.Errorf("Expected a game with %+v to be created, but got %+v", expected, games.created)
+	}
+}
+
+func TestStartGameSessionNotSaved(t *testing.T) {
+	games := newFakeGameService()
+	h := newTestHandler(t, games)
+
+	form := url.Values{"grid-size": {"6"}, "mines-amount": {"5"}, "win-rule": {"strict"}}
+	request := httptest.NewRequest(http.MethodPost, "/start-game", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// a cookie that wasn't signed with the store's keys can't be read, so the session can't be saved
+	request.AddCookie(&http.Cookie{Name: sessionName, Value: "forged"})
+	recorder := httptest.NewRecorder()
+
+	h.StartGame(recorder, request)
+
+	if recorder.Code == http.StatusOK {
+		t.Fatalf("Expected the game to fail without a session, but got %d", recorder.Code)
+	}
+	if len(recorder.Result().Cookies()) != 0 {
+		t.Errorf("Expected no session cookie, but got %v", recorder.Result().Cookies())
+	}
+	if len(games.games) != 0 {
+		t.Errorf("Expected the game that isn't in the session to be deleted, but got %d games", len(games.games))
+	}
+}
+
+func TestStartGameInvalidSettings(t *testing.T) {
+	games := newFakeGameService()
+	h := newTestHandler(t, games)
+
+	form := url.Values{"grid-size": {"1"}, "mines-amount": {"5"}}
+	request := httptest.NewRequest(http.MethodPost, "/start-game", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("HX-Request", "true")
+	recorder := httptest.NewRecorder()
+
+	h.StartGame(recorder, request)
+
+	if recorder.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d, but got %d", http.StatusUnprocessableEntity, recorder.Code)
+	}
+	if !strings.Contains(recorder.Body.String(), "grid size must be between") {
+		t.Errorf("Expected the validation error to be rendered, but got %s", recorder.Body.String())
+	}
+	if len(games.created) != 0 {
+		t.Errorf("Expected no game to be created")
+	}
+}
+
+// serve sends the request through the application routes.
+func serve(h *Handler, request *http.Request) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	Routes(h, NewApiHandler(h.Templates, h.Store, h.Queries)).ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestStartGameUnfinishedGamesCap(t *testing.T) {
+	testCases := []struct {
+		name             string
+		notFinishedGames int
+		expectedStatus   int
+	}{
+		{"Below the cap", 1, http.StatusOK},
+		{"At the cap", 2, http.StatusConflict},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			games := newFakeGameService()
+			games.stats = game.Stats{TotalGames: 3, NotFinishedGames: tc.notFinishedGames}
+			h := newTestHandler(t, games)
+			h.Limits.MaxUnfinishedGames = 2
+
+			form := url.Values{"grid-size": {"6"}, "mines-amount": {"5"}}
+			request := httptest.NewRequest(http.MethodPost, "/games", strings.NewReader(form.Encode()))
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			request.Header.Set("HX-Request", "true")
+			request.AddCookie(sessionCookie(t, h, &models.Game{Uuid: "uuid-1"}))
+			recorder := httptest.NewRecorder()
+
+			h.StartGame(recorder, request)
+
+			if recorder.Code != tc.expectedStatus {
+				t.Fatalf("Expected status %d, but got %d: %s", tc.expectedStatus, recorder.Code, recorder.Body.String())
+			}
+			if tc.expectedStatus == http.StatusConflict {
+				if !strings.Contains(recorder.Body.String(), "You have 2 unfinished games") {
+					t.Errorf("Expected the cap to be explained, but got %s", recorder.Body.String())
+				}
+				if len(games.created) != 0 {
+					t.Errorf("Expected no game to be created")
+				}
+			}
+		})
+	}
+}
+
+func TestLoadGame(t *testing.T) {
+	testCases := []struct {
+		name           string
+		uuid           string
+		loadErr        error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"Existing game", "uuid-1", nil, http.StatusOK, "Number of Mines: 3"},
+		{"Unknown game", "unknown", nil, http.StatusNotFound, "There is no game with such uuid."},
+		{
+			"Corrupt game", "uuid-1",
+			fmt.Errorf("%w: %w", game.ErrCorruptGame, errors.New("bad base64 at offset 3")),
+			http.StatusInternalServerError, "The saved state of this game is corrupted",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			games := newFakeGameService()
+			games.loadErr = tc.loadErr
+			h := newTestHandler(t, games)
+
+			existingGame := models.NewGame(4, 3, models.WinRuleClassic)
+			existingGame.Uuid = "uuid-1"
+			games.games[existingGame.Uuid] = existingGame
+
+			request := httptest.NewRequest(http.MethodGet, "/games/"+tc.uuid, nil)
+			request.Header.Set("HX-Request", "true")
+
+			recorder := serve(h, request)
+
+			if recorder.Code != tc.expectedStatus {
+				t.Errorf("Expected status %d, but got %d", tc.expectedStatus, recorder.Code)
+			}
+			if !strings.Contains(recorder.Body.String(), tc.expectedBody) {
+				t.Errorf("Expected body to contain '%s', but got %s", tc.expectedBody, recorder.Body.String())
+			}
+			if strings.Contains(recorder.Body.String(), "base64") {
+				t.Errorf("Expected decoding details not to be shown to the player")
+			}
+		})
+	}
+}
+
+func TestLoadGameFromLink(t *testing.T) {
+	h := newTestHandler(t, newFakeGameService())
+
+	recorder := serve(h, httptest.NewRequest(http.MethodGet, "/games/uuid-1", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, but got %d", http.StatusOK, recorder.Code)
+	}
+	if !strings.Contains(recorder.Body.String(), `hx-get="/games/uuid-1"`) {
+		t.Errorf("Expected the home page to load the game, but got %s", recorder.Body.String())
+	}
+}
+
+func newMoveRequest(gameUuid string, form string) *http.Request {
+	request := httptest.NewRequest(http.MethodPost, "/games/"+gameUuid+"/moves", strings.NewReader(form))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("HX-Request", "true")
+	return request
+}
+
+func TestHandleGridAction(t *testing.T) {
+	testCases := []struct {
+		name           string
+		uuid           string
+		form           string
+		actErr         error
+		expectedStatus int
+		expectedActs   int
+	}{
+		{"Reveal cell", "uuid-current", "action=reveal_cell&row=1&col=2", nil, http.StatusOK, 1},
+		{"Older game of the session", "uuid-older", "action=flag_cell&row=0&col=0", nil, http.StatusOK, 1},
+		{"Game not in session", "uuid-other", "action=reveal_cell&row=1&col=2", nil, http.StatusConflict, 0},
+		{"Missing row", "uuid-current", "action=reveal_cell&col=2", nil, http.StatusUnprocessableEntity, 0},
+		{"Invalid action", "uuid-current", "action=explode&row=1&col=2", game.ErrInvalidAction, http.StatusUnprocessableEntity, 1},
+		{"Finished game", "uuid-current", "action=flag_cell&row=1&col=2", game.ErrGameOver, http.StatusConflict, 1},
+		{"Database failure", "uuid-current", "action=flag_cell&row=1&col=2", errors.New("disk I/O error"), http.StatusInternalServerError, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			games := newFakeGameService()
+			games.actErr = tc.actErr
+			h := newTestHandler(t, games)
+
+			olderGame := models.NewGame(4, 3, models.WinRuleClassic)
+			olderGame.Uuid = "uuid-older"
+			currentGame := models.NewGame(4, 3, models.WinRuleClassic)
+			currentGame.Uuid = "uuid-current"
+			games.games[olderGame.Uuid] = olderGame
+			games.games[currentGame.Uuid] = currentGame
+
+			request := newMoveRequest(tc.uuid, tc.form)
+			request.AddCookie(sessionCookie(t, h, olderGame, currentGame))
+
+			recorder := serve(h, request)
+
+			if recorder.Code != tc.expectedStatus {
+				t.Errorf("Expected status %d, but got %d", tc.expectedStatus, recorder.Code)
+			}
+			if len(games.acted) != tc.expectedActs {
+				t.Fatalf("Expected %d moves, but got %v", tc.expectedActs, games.acted)
+			}
+			if tc.expectedActs > 0 && !strings.HasPrefix(games.acted[0], tc.uuid+" ") {
+				t.Errorf("Expected the move to be made in game %s, but got %s", tc.uuid, games.acted[0])
+			}
+			if strings.Contains(recorder.Body.String(), "disk I/O") {
+				t.Errorf("Expected database errors not to be shown to the player")
+			}
+		})
+	}
+}
+
+func TestHandleGridActionWithoutSession(t *testing.T) {
+	games := newFakeGameService()
+	h := newTestHandler(t, games)
+
+	recorder := serve(h, newMoveRequest("uuid-1", "action=reveal_cell&row=1&col=2"))
+
+	if recorder.Code != http.StatusConflict {
+		t.Errorf("Expected status %d, but got %d", http.StatusConflict, recorder.Code)
+	}
+	if len(games.acted) != 0 {
+		t.Errorf("Expected no move to be made, but got %v", games.acted)
+	}
+}
+
+func TestSessionGamesInfo(t *testing.T) {
+	games := newFakeGameService()
+	games.stats = game.Stats{TotalGames: 7, WonGames: 3, LostGames: 2, NotFinishedGames: 2}
+	h := newTestHandler(t, games)
+
+	request := httptest.NewRequest(http.MethodGet, "/session-games-info", nil)
+	request.AddCookie(sessionCookie(t, h, &models.Game{Uuid: "uuid-1"}))
+	recorder := httptest.NewRecorder()
+
+	h.SessionGamesInfo(recorder, request)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, but got %d", http.StatusOK, recorder.Code)
+	}
+	if !strings.Contains(recorder.Body.String(), ">7</span") {
+		t.Errorf("Expected the total games to be rendered, but got %s", recorder.Body.String())
+	}
+}
@@ -0,0 +1,184 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log/slog"
+	"minesweeper/db/migrations"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// ReadinessDatabase is the part of storage.Database the readiness probe relies on.
+type ReadinessDatabase interface {
+	PingContext(ctx context.Context) error
+	CheckMigrations(ctx context.Context) (migrations.SchemaVersion, error)
+}
+
+// readinessTimeout bounds the checks of a readiness probe, a database that takes longer is not ready.
+const readinessTimeout = 2 * time.Second
+
+// templatesRequiredForReadiness are the entry templates of the pages, without them nothing can be served.
+var templatesRequiredForReadiness = []string{"index", "game_layout", "error_message", "index_games_page", "charts_page"}
+
+// HealthHandler serves the probes used by the container orchestration.
+type HealthHandler struct {
+	Database  ReadinessDatabase
+	Templates *template.Template
+}
+
+func NewHealthHandler(database ReadinessDatabase, templates *template.Template) *HealthHandler {
+	return &HealthHandler{Database: database, Templates: templates}
+}
+
+const (
+	healthzRoute = "GET /healthz"
+	readyzRoute  = "GET /readyz"
+	versionRoute = "GET /version"
+	metricsRoute = "GET /metrics"
+)
+
+// probeRoutes are polled by the orchestration and the monitoring rather than requested by players.
+var probeRoutes = map[string]bool{healthzRoute: true, readyzRoute: true, metricsRoute: true}
+
+// ProbeRoutes serves the probes and, unless it is nil, the metrics handler next to app. They are kept
+// out of the app middleware, so a probe or a scrape neither gets a session nor counts as a visitor.
+func ProbeRoutes(health *HealthHandler, metrics http.Handler, app http.Handler) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.Handle(healthzRoute, withRoute(healthzRoute, http.HandlerFunc(health.Healthz)))
+	mux.Handle(readyzRoute, withRoute(readyzRoute, http.HandlerFunc(health.Readyz)))
+	mux.Handle(versionRoute, withRoute(versionRoute, http.HandlerFunc(health.Version)))
+	if metrics != nil {
+		mux.Handle(metricsRoute, withRoute(metricsRoute, metrics))
+	}
+	mux.Handle("/", app)
+
+	return mux
+}
+
+// Healthz tells that the process is alive and serving requests.
+func (h *HealthHandler) Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
+
+// Readyz tells whether the server can handle traffic: the database answers, its schema is migrated
+// and the templates are parsed. It responds with 503 Service Unavailable while any check fails.
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	checks := []struct {
+		name  string
+		check func(ctx context.Context) error
+	}{
+		{"database", h.checkDatabase},
+		{"migrations", h.checkMigrations},
+		{"templates", h.checkTemplates},
+	}
+
+	status := http.StatusOK
+	results := make(map[string]string, len(checks))
+
+	for _, c := range checks {
+		if err := c.check(ctx); err != nil {
+			// the details may name hosts or files, they are only logged
+			slog.WarnContext(r.Context(), "Readiness check failed", "check", c.name, "error", err)
+			results[c.name] = "failed"
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		results[c.name] = "ok"
+	}
+
+	response := struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks"`
+	}{
+		Status: "ready",
+		Checks: results,
+	}
+	if status != http.StatusOK {
+		response.Status = "not ready"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *HealthHandler) checkDatabase(ctx context.Context) error {
+	if h.Database == nil {
+		return errors.New("no database")
+	}
+	return h.Database.PingContext(ctx)
+}
+
+func (h *HealthHandler) checkMigrations(ctx context.Context) error {
+	if h.Database == nil {
+		return errors.New("no database")
+	}
+	_, err := h.Database.CheckMigrations(ctx)
+	return err
+}
+
+func (h *HealthHandler) checkTemplates(ctx context.Context) error {
+	if h.Templates == nil {
+		return errors.New("templates are not parsed")
+	}
+
+	for _, name := range templatesRequiredForReadiness {
+		if h.Templates.Lookup(name) == nil {
+			return errors.New("template " + name + " is missing")
+		}
+	}
+	return nil
+}
+
+// BuildVersion describes the build of the running binary.
+type BuildVersion struct {
+	Module    string `json:"module"`
+	Version   string `json:"version"`
+	GoVersion string `json:"go_version"`
+	Revision  string `json:"revision,omitempty"`
+	Time      string `json:"time,omitempty"`
+	Modified  bool   `json:"modified"`
+}
+
+// ReadBuildVersion reads the version of the binary from the build info embedded by the Go toolchain.
+// The VCS fields are only set for binaries built from a git checkout.
+func ReadBuildVersion() BuildVersion {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return BuildVersion{Version: "unknown"}
+	}
+
+	version := BuildVersion{
+		Module:    info.Main.Path,
+		Version:   info.Main.Version,
+		GoVersion: info.GoVersion,
+	}
+
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			version.Revision = setting.Value
+		case "vcs.time":
+			version.Time = setting.Value
+		case "vcs.modified":
+			version.Modified = setting.Value == "true"
+		}
+	}
+
+	return version
+}
+
+// Version responds with the build of the running binary, see ReadBuildVersion.
+func (h *HealthHandler) Version(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ReadBuildVersion())
+}
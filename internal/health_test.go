@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"minesweeper/db/migrations"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type fakeReadinessDatabase struct {
+	pingErr       error
+	migrationsErr error
+}
+
+func (f *fakeReadinessDatabase) PingContext(ctx context.Context) error {
+	return f.pingErr
+}
+
+func (f *fakeReadinessDatabase) CheckMigrations(ctx context.Context) (migrations.SchemaVersion, error) {
+	return migrations.SchemaVersion{Current: 3, Latest: 3}, f.migrationsErr
+}
+
+func TestReadyz(t *testing.T) {
+	testCases := []struct {
+		name           string
+		database       *fakeReadinessDatabase
+		withTemplates  bool
+		expectedStatus int
+		expectedChecks map[string]string
+	}{
+		{
+			"Ready", &fakeReadinessDatabase{}, true, http.StatusOK,
+			map[string]string{"database": "ok", "migrations": "ok", "templates": "ok"},
+		},
+		{
+			"Database down", &fakeReadinessDatabase{pingErr: errors.New("dial tcp 10.0.0.5:5432: connection refused"), migrationsErr: errors.New("connection refused")}, true, http.StatusServiceUnavailable,
+			map[string]string{"database": "failed", "migrations": "failed", "templates": "ok"},
+		},
+		{
+			"Migrations pending", &fakeReadinessDatabase{migrationsErr: migrations.ErrSchemaOutdated}, true, http.StatusServiceUnavailable,
+			map[string]string{"database": "ok", "migrations": "failed", "templates": "ok"},
+		},
+		{
+			"Templates missing", &fakeReadinessDatabase{}, false, http.StatusServiceUnavailable,
+			map[string]string{"database": "ok", "migrations": "ok", "templates": "failed"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			health := NewHealthHandler(tc.database, nil)
+			if tc.withTemplates {
+				health.Templates = newTestHandler(t, newFakeGameService()).Templates
+			}
+
+			recorder := httptest.NewRecorder()
+			ProbeRoutes(health, nil, http.NotFoundHandler()).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			if recorder.Code != tc.expectedStatus {
+				t.Errorf("Expected status %d, but got %d", tc.expectedStatus, recorder.Code)
+			}
+			if strings.Contains(recorder.Body.String(), "10.0.0.5") {
+				t.Errorf("Expected the failure details not to be exposed, but got %s", recorder.Body.String())
+			}
+
+			var response struct {
+				Checks map[string]string `json:"checks"`
+			}
+			if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			for name, expected := range tc.expectedChecks {
+				if response.Checks[name] != expected {
+					t.Errorf("Expected check %s to be %s, but got %s", name, expected, response.Checks[name])
+				}
+			}
+		})
+	}
+}
+
+func TestProbeRoutes(t *testing.T) {
+	app := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "minesweeper-session=new")
+		w.Write([]byte("app"))
+	})
+	mux := ProbeRoutes(NewHealthHandler(&fakeReadinessDatabase{}, nil), nil, app)
+
+	testCases := []struct {
+		path         string
+		expectedBody string
+	}{
+		{"/healthz", "ok"},
+		{"/version", `"go_version"`},
+		{"/", "app"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			if recorder.Code != http.StatusOK {
+				t.Errorf("Expected status %d, but got %d", http.StatusOK, recorder.Code)
+			}
+			if !strings.Contains(recorder.Body.String(), tc.expectedBody) {
+				t.Errorf("Expected body to contain '%s', but got %s", tc.expectedBody, recorder.Body.String())
+			}
+			if tc.path != "/" && recorder.Header().Get("Set-Cookie") != "" {
+				t.Errorf("Expected probes not to go through the app")
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// NewLogger returns a logger writing JSON records of at least level to w. Records logged with the context
+// of a request carry its request_id, see RequestID.
+func NewLogger(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(&requestIDHandler{slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})})
+}
+
+// requestIDHandler adds the request ID found in the context to every record.
+type requestIDHandler struct {
+	slog.Handler
+}
+
+func (h *requestIDHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *requestIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &requestIDHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *requestIDHandler) WithGroup(name string) slog.Handler {
+	return &requestIDHandler{h.Handler.WithGroup(name)}
+}
+
+// AccessLog logs every served request, it is an HTTPObserver meant to be passed to Instrument.
+type AccessLog struct {
+	Logger *slog.Logger
+}
+
+var _ HTTPObserver = (*AccessLog)(nil)
+
+func NewAccessLog(logger *slog.Logger) *AccessLog {
+	return &AccessLog{Logger: logger}
+}
+
+// RequestServed logs the request at the info level, the probes and scrapes polled every few seconds
+// are only logged at the debug level.
+func (a *AccessLog) RequestServed(r *http.Request, route string, status int, duration time.Duration) {
+	level := slog.LevelInfo
+	if probeRoutes[route] {
+		level = slog.LevelDebug
+	}
+
+	a.Logger.LogAttrs(r.Context(), level, "Request served",
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.String("route", route),
+		slog.Int("status", status),
+		slog.Float64("duration_ms", float64(duration.Microseconds())/1000),
+		slog.String("remote_addr", r.RemoteAddr),
+		slog.String("user_agent", r.UserAgent()),
+	)
+}
+
+// HTTPObservers notifies each of its observers, so Instrument can feed both the metrics and the access log.
+type HTTPObservers []HTTPObserver
+
+func (o HTTPObservers) RequestServed(r *http.Request, route string, status int, duration time.Duration) {
+	for _, observer := range o {
+		observer.RequestServed(r, route, status, duration)
+	}
+}
@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// decodeRecords decodes the JSON records written by a logger, one per line.
+func decodeRecords(t *testing.T, logs *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Expected a JSON record, but got %s", line)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestNewLoggerAddsRequestID(t *testing.T) {
+	var logs bytes.Buffer
+	logger := NewLogger(&logs, slog.LevelInfo)
+
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.With("component", "test").InfoContext(r.Context(), "Handled")
+		logger.DebugContext(r.Context(), "Not logged")
+	}))
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set(RequestIDHeader, "req-1")
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+	logger.Info("Outside of a request")
+
+	records := decodeRecords(t, &logs)
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records above the debug level, but got %d", len(records))
+	}
+	if records[0]["request_id"] != "req-1" || records[0]["component"] != "test" {
+		t.Errorf("Expected the request ID and the attributes of the logger, but got %v", records[0])
+	}
+	if _, ok := records[1]["request_id"]; ok {
+		t.Errorf("Expected no request ID outside of a request, but got %v", records[1])
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	var logs bytes.Buffer
+	accessLog := NewAccessLog(NewLogger(&logs, slog.LevelInfo))
+
+	h := newTestHandler(t, newFakeGameService())
+	app := Routes(h, NewApiHandler(h.Templates, h.Store, h.Queries))
+	handler := RequestID(Instrument(accessLog, ProbeRoutes(NewHealthHandler(nil, nil), nil, app)))
+
+	for _, path := range []string{"/", "/healthz"} {
+		request := httptest.NewRequest(http.MethodGet, path, nil)
+		request.Header.Set(RequestIDHeader, "req-2")
+		handler.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	records := decodeRecords(t, &logs)
+	if len(records) != 1 {
+		t.Fatalf("Expected only the request of a page to be logged at the info level, but got %v", records)
+	}
+
+	expected := map[string]any{
+		"msg":        "Request served",
+		"method":     http.MethodGet,
+		"path":       "/",
+		"route":      "GET /{$}",
+		"status":     float64(http.StatusOK),
+		"request_id": "req-2",
+	}
+	for key, value := range expected {
+		if records[0][key] != value {
+			t.Errorf("Expected %s to be %v, but got %v", key, value, records[0][key])
+		}
+	}
+}
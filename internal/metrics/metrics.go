@@ -0,0 +1,150 @@
+// Package metrics exports the HTTP, gameplay and database metrics of the server in the Prometheus format.
+package metrics
+
+import (
+	"database/sql"
+	"minesweeper/internal"
+	"minesweeper/internal/game"
+	"minesweeper/internal/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "minesweeper"
+
+// Metrics collects the metrics of the server in its own registry.
+//
+// It observes the HTTP requests through internal.Instrument and the games through game.Observer.
+type Metrics struct {
+	game.NopObserver
+
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+
+	gamesStarted *prometheus.CounterVec
+	gamesEnded   *prometheus.CounterVec
+	moves        *prometheus.CounterVec
+	gridCoding   *prometheus.HistogramVec
+}
+
+var (
+	_ internal.HTTPObserver = (*Metrics)(nil)
+	_ game.Observer         = (*Metrics)(nil)
+)
+
+// New returns the metrics registered together with the Go runtime and process metrics.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests served, by route pattern, method and status code.",
+		}, []string{"route", "method", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of the HTTP requests, by route pattern and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+
+		gamesStarted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "games_started_total",
+			Help:      "Games started, by grid size.",
+		}, []string{"grid_size"}),
+		gamesEnded: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "games_ended_total",
+			Help:      "Games won, lost or abandoned, by grid size and result.",
+		}, []string{"grid_size", "result"}),
+		moves: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "moves_total",
+			Help:      "Moves made, by action.",
+		}, []string{"action"}),
+		gridCoding: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "grid_coding_duration_seconds",
+			Help:      "Time spent encoding grids for storage and decoding stored grids, by operation.",
+			// from 10µs to about 160ms, large grids take a few milliseconds
+			Buckets: prometheus.ExponentialBuckets(0.00001, 4, 8),
+		}, []string{"operation"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.gamesStarted,
+		m.gamesEnded,
+		m.moves,
+		m.gridCoding,
+	)
+
+	return m
+}
+
+// RegisterDB exports the connection pool stats of database, like open, in use and idle connections.
+func (m *Metrics) RegisterDB(database *sql.DB) error {
+	return m.registry.Register(collectors.NewDBStatsCollector(database, namespace))
+}
+
+// Handler serves the metrics to a Prometheus scrape.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+func (m *Metrics) RequestServed(r *http.Request, route string, status int, duration time.Duration) {
+	method := methodLabel(r.Method)
+	m.httpRequests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	m.httpDuration.WithLabelValues(route, method).Observe(duration.Seconds())
+}
+
+// methodLabel keeps made up methods sent to unmatched routes from adding label values.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions, http.MethodConnect, http.MethodTrace:
+		return method
+	default:
+		return "OTHER"
+	}
+}
+
+func (m *Metrics) GameStarted(startedGame *models.Game) {
+	m.gamesStarted.WithLabelValues(strconv.Itoa(startedGame.GridSize)).Inc()
+}
+
+func (m *Metrics) MoveMade(playedGame *models.Game, action game.Action) {
+	m.moves.WithLabelValues(string(action)).Inc()
+}
+
+func (m *Metrics) GameEnded(endedGame *models.Game) {
+	result := "lost"
+	if endedGame.GameWon {
+		result = "won"
+	}
+	m.gamesEnded.WithLabelValues(strconv.Itoa(endedGame.GridSize), result).Inc()
+}
+
+func (m *Metrics) GameAbandoned(abandonedGame *models.Game) {
+	m.gamesEnded.WithLabelValues(strconv.Itoa(abandonedGame.GridSize), "abandoned").Inc()
+}
+
+func (m *Metrics) GridEncoded(duration time.Duration) {
+	m.gridCoding.WithLabelValues("encode").Observe(duration.Seconds())
+}
+
+func (m *Metrics) GridDecoded(duration time.Duration) {
+	m.gridCoding.WithLabelValues("decode").Observe(duration.Seconds())
+}
@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"database/sql"
+	"minesweeper/internal/game"
+	"minesweeper/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestGameMetrics(t *testing.T) {
+	m := New()
+
+	wonGame := &models.Game{GridSize: 8, GameWon: true}
+	lostGame := &models.Game{GridSize: 8, GameFailed: true}
+	abandonedGame := &models.Game{GridSize: 8, GameFailed: true}
+
+	m.GameStarted(wonGame)
+	m.GameStarted(lostGame)
+	m.MoveMade(wonGame, game.ActionRevealCell)
+	m.MoveMade(wonGame, game.ActionFlagCell)
+	m.MoveMade(lostGame, game.ActionRevealCell)
+	m.GameEnded(wonGame)
+	m.GameEnded(lostGame)
+	m.GameAbandoned(abandonedGame)
+	m.GridEncoded(50 * time.Microsecond)
+	m.GridDecoded(80 * time.Microsecond)
+
+	testCases := []struct {
+		name     string
+		value    float64
+		expected float64
+	}{
+		{"games started", testutil.ToFloat64(m.gamesStarted.WithLabelValues("8")), 2},
+		{"games won", testutil.ToFloat64(m.gamesEnded.WithLabelValues("8", "won")), 1},
+		{"games lost", testutil.ToFloat64(m.gamesEnded.WithLabelValues("8", "lost")), 1},
+		{"games abandoned", testutil.ToFloat64(m.gamesEnded.WithLabelValues("8", "abandoned")), 1},
+		{"reveal moves", testutil.ToFloat64(m.moves.WithLabelValues(string(game.ActionRevealCell))), 2},
+		{"flag moves", testutil.ToFloat64(m.moves.WithLabelValues(string(game.ActionFlagCell))), 1},
+	}
+
+	for _, tc := range testCases {
+		if tc.value != tc.expected {
+			t.Errorf("Expected %v %s, but got %v", tc.expected, tc.name, tc.value)
+		}
+	}
+
+	if count := testutil.CollectAndCount(m.gridCoding); count != 2 {
+		t.Errorf("Expected an encode and a decode series, but got %d", count)
+	}
+}
+
+func TestHTTPMetrics(t *testing.T) {
+	m := New()
+
+	m.RequestServed(httptest.NewRequest(http.MethodGet, "/games/uuid-1", nil), "GET /games/{uuid}", http.StatusOK, 20*time.Millisecond)
+	m.RequestServed(httptest.NewRequest(http.MethodGet, "/games/uuid-2", nil), "GET /games/{uuid}", http.StatusOK, 30*time.Millisecond)
+	m.RequestServed(httptest.NewRequest("BREW", "/coffee", nil), "unmatched", http.StatusNotFound, time.Millisecond)
+
+	if value := testutil.ToFloat64(m.httpRequests.WithLabelValues("GET /games/{uuid}", http.MethodGet, "200")); value != 2 {
+		t.Errorf("Expected 2 requests of the route, but got %v", value)
+	}
+	if value := testutil.ToFloat64(m.httpRequests.WithLabelValues("unmatched", "OTHER", "404")); value != 1 {
+		t.Errorf("Expected the unknown method to be reported as OTHER, but got %v", value)
+	}
+}
+
+func TestHandlerExportsDBStats(t *testing.T) {
+	database, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "minesweeper.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	m := New()
+	if err := m.RegisterDB(database); err != nil {
+		t.Fatalf("Failed to register database metrics: %v", err)
+	}
+	m.GameStarted(&models.Game{GridSize: 4})
+
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	for _, expected := range []string{
+		`minesweeper_games_started_total{grid_size="4"} 1`,
+		`go_sql_open_connections{db_name="minesweeper"}`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(recorder.Body.String(), expected) {
+			t.Errorf("Expected the metrics to contain %s", expected)
+		}
+	}
+}
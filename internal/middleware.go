@@ -0,0 +1,127 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+type contextKey string
+
+const requestIDContextKey contextKey = "request_id"
+
+// RequestIDHeader carries the request ID, it is accepted from a proxy in front of the server and always sent back.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID assigns every request an ID, so the log lines of a failed request can be found from what the user saw.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDContextKey, requestID)))
+	})
+}
+
+// RequestIDFromContext returns the ID assigned by RequestID, or an empty string outside of it.
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey).(string)
+	return requestID
+}
+
+// routeContextKey holds the *string the pattern of the matched route is written to, see Instrument.
+const routeContextKey contextKey = "route"
+
+// UnmatchedRoute is reported for requests no route matched, like 404s and 405s of the mux.
+const UnmatchedRoute = "unmatched"
+
+// HTTPObserver is notified about every served request, e.g. to export metrics.
+type HTTPObserver interface {
+	RequestServed(r *http.Request, route string, status int, duration time.Duration)
+}
+
+// Instrument reports every request to observer, with the pattern of the route that served it.
+// The pattern is used rather than the path so a route is reported the same for every game uuid.
+func Instrument(observer HTTPObserver, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		route := UnmatchedRoute
+		recorder := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), routeContextKey, &route)))
+
+		observer.RequestServed(r, route, recorder.Status(), time.Since(start))
+	})
+}
+
+// withRoute records pattern as the route of the requests served by handler, see Instrument.
+func withRoute(pattern string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route, ok := r.Context().Value(routeContextKey).(*string); ok {
+			*route = pattern
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// statusRecorder remembers the status code written to the wrapped ResponseWriter.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap gives http.ResponseController access to the wrapped ResponseWriter.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Status returns the written status code, a handler that wrote nothing responded with 200 OK.
+func (r *statusRecorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// validRequestID only accepts short IDs made of characters that are safe to write into the logs.
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > 64 {
+		return false
+	}
+
+	for _, c := range requestID {
+		isAlphanumeric := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if !isAlphanumeric && c != '-' && c != '_' && c != '.' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+)
+
+var benchmarkGridSizes = []int{22, 100, 250}
+
+// emptyGame returns a game whose only mine sits in the bottom right corner,
+// so revealing the top left cell floods almost the whole grid.
+func emptyGame(gridSize int) *Game {
+	game := NewGame(gridSize, 0, WinRuleClassic)
+	game.MinesAmount = 1
+	game.Grid[gridSize-1][gridSize-1].HasMine = true
+	updateAdjacentCells(game.Grid, gridSize-1, gridSize-1, gridSize)
+
+	return game
+}
+
+func BenchmarkRevealCellFloodFill(b *testing.B) {
+	for _, gridSize := range benchmarkGridSizes {
+		b.Run(fmt.Sprintf("%dx%d", gridSize, gridSize), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				game := emptyGame(gridSize)
+				b.StartTimer()
+
+				game.RevealCell(0, 0)
+			}
+		})
+	}
+}
+
+func BenchmarkFlagCell(b *testing.B) {
+	for _, gridSize := range benchmarkGridSizes {
+		b.Run(fmt.Sprintf("%dx%d", gridSize, gridSize), func(b *testing.B) {
+			game := NewGame(gridSize, gridSize*gridSize/5, WinRuleStrict)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				game.FlagCell(i%gridSize, (i/gridSize)%gridSize)
+			}
+		})
+	}
+}
+
+func BenchmarkEncodeGameGrid(b *testing.B) {
+	for _, gridSize := range benchmarkGridSizes {
+		b.Run(fmt.Sprintf("%dx%d", gridSize, gridSize), func(b *testing.B) {
+			game := NewGame(gridSize, gridSize*gridSize/5, WinRuleClassic)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				EncodeGameGrid(game.Grid)
+			}
+		})
+	}
+}
+
+func BenchmarkDecodeGameGrid(b *testing.B) {
+	for _, gridSize := range benchmarkGridSizes {
+		b.Run(fmt.Sprintf("%dx%d", gridSize, gridSize), func(b *testing.B) {
+			encodedGameGrid := EncodeGameGrid(NewGame(gridSize, gridSize*gridSize/5, WinRuleClassic).Grid)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := DecodeGameGrid(encodedGameGrid, gridSize); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -1,7 +1,11 @@
 package models
 
 import (
-	"log"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
 	"math/rand"
 	"minesweeper/internal/db"
 	"strings"
@@ -14,14 +18,41 @@ type Cell struct {
 	AdjacentMines int
 }
 
+// WinRule decides when a game counts as won.
+type WinRule string
+
+const (
+	// WinRuleClassic wins the game once every safe cell is revealed, the mines are flagged automatically.
+	WinRuleClassic WinRule = "classic"
+	// WinRuleStrict additionally requires every mine, and only the mines, to be flagged.
+	WinRuleStrict WinRule = "strict"
+)
+
+// ParseWinRule converts the stored or submitted name of a rule into a WinRule.
+func ParseWinRule(rule string) (WinRule, error) {
+	switch WinRule(rule) {
+	case WinRuleClassic, WinRuleStrict:
+		return WinRule(rule), nil
+	default:
+		return "", fmt.Errorf("unknown win rule %q", rule)
+	}
+}
+
 type Game struct {
 	Id          int64
 	Uuid        string
 	GridSize    int
 	MinesAmount int
+	WinRule     WinRule
 	Grid        [][]Cell
 	GameFailed  bool
 	GameWon     bool
+
+	// RevealedCells and FlaggedCells are kept up to date by every move,
+	// so the win condition never has to rescan the whole grid.
+	RevealedCells int
+	FlaggedCells  int
+	flaggedMines  int
 }
 
 func updateAdjacentCells(grid [][]Cell, row int, col int, gridSize int) {
@@ -38,7 +69,7 @@ func updateAdjacentCells(grid [][]Cell, row int, col int, gridSize int) {
 	}
 }
 
-func NewGame(gridSize int, minesAmount int) *Game {
+func NewGame(gridSize int, minesAmount int, winRule WinRule) *Game {
 	grid := make([][]Cell, gridSize)
 
 	for i := range grid {
@@ -68,27 +99,106 @@ func NewGame(gridSize int, minesAmount int) *Game {
 	return &Game{
 		GridSize:    gridSize,
 		MinesAmount: minesAmount,
+		WinRule:     winRule,
 		Grid:        grid,
 	}
 }
 
-func (g *Game) revealSurroundingCells(row int, col int) {
-	for i := -1; i <= 1; i++ {
-		for j := -1; j <= 1; j++ {
-			if i == 0 && j == 0 {
-				continue // we are skipping the current cell
+// CloneBoard returns a new, unplayed game with the same mines as g, so the board can be played again.
+func (g *Game) CloneBoard() *Game {
+	grid := make([][]Cell, g.GridSize)
+	for i := range grid {
+		grid[i] = make([]Cell, g.GridSize)
+		for j, cell := range g.Grid[i] {
+			grid[i][j] = Cell{HasMine: cell.HasMine, AdjacentMines: cell.AdjacentMines}
+		}
+	}
+
+	return &Game{
+		GridSize:    g.GridSize,
+		MinesAmount: g.MinesAmount,
+		WinRule:     g.WinRule,
+		Grid:        grid,
+	}
+}
+
+// recountCells recomputes the cell counters from the grid, it is needed whenever a grid is built from scratch.
+func (g *Game) recountCells() {
+	g.RevealedCells, g.FlaggedCells, g.flaggedMines = 0, 0, 0
+
+	for _, row := range g.Grid {
+		for _, cell := range row {
+			if cell.IsRevealed {
+				g.RevealedCells++
 			}
 
-			r, c := row+i, col+j
+			if cell.IsFlagged {
+				g.FlaggedCells++
+				if cell.HasMine {
+					g.flaggedMines++
+				}
+			}
+		}
+	}
+}
+
+func (g *Game) revealCell(cell *Cell) {
+	cell.IsRevealed = true
+	g.RevealedCells++
+
+	// the flood fill may reach flagged cells, a revealed cell is never flagged
+	if cell.IsFlagged {
+		g.setFlag(cell, false)
+	}
+}
+
+func (g *Game) setFlag(cell *Cell, flagged bool) {
+	if cell.IsFlagged == flagged {
+		return
+	}
+
+	cell.IsFlagged = flagged
+
+	delta := 1
+	if !flagged {
+		delta = -1
+	}
+
+	g.FlaggedCells += delta
+	if cell.HasMine {
+		g.flaggedMines += delta
+	}
+}
+
+// revealSurroundingCells reveals the area of safe cells connected to the given cell.
+// It uses a queue instead of recursion, so the depth of the fill is not bound by the stack on large grids.
+func (g *Game) revealSurroundingCells(row int, col int) {
+	queue := []int{row*g.GridSize + col}
 
-			if r >= 0 && r < g.GridSize && c >= 0 && c < g.GridSize {
-				neighbor := &g.Grid[r][c]
+	for head := 0; head < len(queue); head++ {
+		r, c := queue[head]/g.GridSize, queue[head]%g.GridSize
 
-				if !neighbor.IsRevealed && !neighbor.HasMine {
-					neighbor.IsRevealed = true
-					if neighbor.AdjacentMines == 0 {
-						g.revealSurroundingCells(r, c)
-					}
+		for i := -1; i <= 1; i++ {
+			for j := -1; j <= 1; j++ {
+				if i == 0 && j == 0 {
+					continue // we are skipping the current cell
+				}
+
+				nr, nc := r+i, c+j
+
+				if nr < 0 || nr >= g.GridSize || nc < 0 || nc >= g.GridSize {
+					continue
+				}
+
+				neighbor := &g.Grid[nr][nc]
+
+				if neighbor.IsRevealed || neighbor.HasMine {
+					continue
+				}
+
+				g.revealCell(neighbor)
+				if neighbor.AdjacentMines == 0 {
+					queue = append(queue, nr*g.GridSize+nc)
 				}
 			}
 		}
@@ -110,21 +220,20 @@ func (g *Game) RevealCell(row int, col int) {
 		return
 	}
 
-	cell.IsRevealed = true
+	g.revealCell(cell)
 
 	if cell.HasMine {
-		log.Printf("Game with UUID: %s is ended and FAILED", g.Uuid)
-
 		g.GameFailed = true
+		g.logEnded("lost")
 		return
 	}
-	g.CheckWinCondition()
 
-	if cell.AdjacentMines > 0 {
-		return
+	// the win condition has to be checked after the flood fill, it may reveal the last safe cells
+	if cell.AdjacentMines == 0 {
+		g.revealSurroundingCells(row, col)
 	}
 
-	g.revealSurroundingCells(row, col)
+	g.CheckWinCondition()
 }
 
 func (g *Game) FlagCell(row int, col int) {
@@ -137,47 +246,76 @@ func (g *Game) FlagCell(row int, col int) {
 		return
 	}
 
-	cell.IsFlagged = !cell.IsFlagged
+	g.setFlag(cell, !cell.IsFlagged)
 
 	g.CheckWinCondition()
 }
 
+// CheckWinCondition checks whether the game is won according to its WinRule and marks it as won if so.
+// It relies on the cell counters only and runs in constant time, apart from the final auto-flagging.
 func (g *Game) CheckWinCondition() bool {
 	if g.GameWon {
 		return g.GameWon
 	}
 
-	var flaggedMines uint16 = 0
-	var revealedCells uint16 = 0
-	totalMines := uint16(g.MinesAmount)
+	if g.GameFailed {
+		return false
+	}
 
-	for _, row := range g.Grid {
-		for _, cell := range row {
-			if cell.HasMine && cell.IsFlagged {
-				flaggedMines++
-			}
+	var won bool
+	if g.WinRule == WinRuleStrict {
+		won = g.strictWinCondition()
+	} else {
+		won = g.classicWinCondition()
+	}
 
-			if cell.IsRevealed {
-				revealedCells++
-			}
+	if !won {
+		return false
+	}
 
-			if !cell.HasMine && cell.IsFlagged {
-				return false
-			}
-		}
+	if g.WinRule != WinRuleStrict {
+		g.flagAllMines()
 	}
 
-	var totalCells = uint16(g.GridSize * g.GridSize)
-	var allEmptyCellsRevealed bool = totalCells-flaggedMines == revealedCells
-	var flaggedAllMines = totalMines == flaggedMines
+	g.GameWon = true
+	g.logEnded("won")
+	return true
+}
 
-	if allEmptyCellsRevealed && flaggedAllMines {
-		log.Printf("Game with UUID: %s is ended and WON", g.Uuid)
-		g.GameWon = true
-		return true
-	}
+// logEnded records the end of the game, result is won or lost.
+func (g *Game) logEnded(result string) {
+	slog.Info("Game ended",
+		"game_uuid", g.Uuid,
+		"result", result,
+		"grid_size", g.GridSize,
+		"mines", g.MinesAmount,
+		"win_rule", g.WinRule,
+		"revealed_cells", g.RevealedCells,
+	)
+}
+
+// classicWinCondition is met once every cell without a mine is revealed, flags are not taken into account.
+func (g *Game) classicWinCondition() bool {
+	return g.RevealedCells == g.GridSize*g.GridSize-g.MinesAmount
+}
+
+// strictWinCondition is met once every cell without a mine is revealed and every mine is flagged.
+// A single flag on a cell without a mine prevents the win.
+func (g *Game) strictWinCondition() bool {
+	flaggedOnlyMines := g.FlaggedCells == g.flaggedMines
+	flaggedAllMines := g.flaggedMines == g.MinesAmount
+
+	return g.classicWinCondition() && flaggedOnlyMines && flaggedAllMines
+}
 
-	return false
+func (g *Game) flagAllMines() {
+	for i := range g.Grid {
+		for j := range g.Grid[i] {
+			if g.Grid[i][j].HasMine {
+				g.setFlag(&g.Grid[i][j], true)
+			}
+		}
+	}
 }
 
 const (
@@ -189,8 +327,128 @@ const (
 	ROW_SEPARATOR     = '|'
 )
 
+const (
+	// GRID_STATE_MARKER prefixes every versioned grid state. It never appears in the
+	// legacy text format, which lets the two formats live side by side in the same column.
+	GRID_STATE_MARKER = '~'
+
+	GRID_FORMAT_TEXT   byte = 0 // legacy one-rune-per-cell format, only ever decoded
+	GRID_FORMAT_PACKED byte = 1 // header byte, uvarint grid size, 3 bits per cell
+
+	CELL_BIT_MINE     = 1 << 0
+	CELL_BIT_FLAGGED  = 1 << 1
+	CELL_BIT_REVEALED = 1 << 2
+	BITS_PER_CELL     = 3
+)
+
+// ErrCorruptGridState is returned, wrapped in a *GridStateError, whenever an encoded grid state cannot be decoded.
+var ErrCorruptGridState = errors.New("corrupt grid state")
+
+// GridStateError describes why an encoded grid state could not be decoded.
+// Row and Col point at the offending cell, they are -1 when the problem is not tied to a single cell.
+type GridStateError struct {
+	Format byte
+	Row    int
+	Col    int
+	Reason string
+}
+
+func (e *GridStateError) Error() string {
+	if e.Row < 0 {
+		return fmt.Sprintf("%v (format %d): %s", ErrCorruptGridState, e.Format, e.Reason)
+	}
+	if e.Col < 0 {
+		return fmt.Sprintf("%v (format %d): row %d: %s", ErrCorruptGridState, e.Format, e.Row, e.Reason)
+	}
+	return fmt.Sprintf("%v (format %d): cell %d,%d: %s", ErrCorruptGridState, e.Format, e.Row, e.Col, e.Reason)
+}
+
+func (e *GridStateError) Unwrap() error {
+	return ErrCorruptGridState
+}
+
+func gridStateError(format byte, reason string, args ...any) *GridStateError {
+	return &GridStateError{Format: format, Row: -1, Col: -1, Reason: fmt.Sprintf(reason, args...)}
+}
+
+// GridStateFormat reports in which format the given encoded grid state was written.
+func GridStateFormat(encodedGameGrid string) byte {
+	if !strings.HasPrefix(encodedGameGrid, string(GRID_STATE_MARKER)) {
+		return GRID_FORMAT_TEXT
+	}
+
+	payload, err := base64.RawStdEncoding.DecodeString(encodedGameGrid[1:])
+	if err != nil || len(payload) == 0 {
+		return GRID_FORMAT_TEXT
+	}
+
+	return payload[0]
+}
+
 // EncodeGameGrid encodes a game grid into a string for easier storage and transport.
 // The encoding is not taking into account the adjacent mines of each cell because it can be easily computed.
+//
+// The grid is written in the GRID_FORMAT_PACKED format:
+//
+// - a header byte holding the format version
+// - the grid size as an uvarint
+// - the cell states bit-packed, 3 bits per cell in row-major order (mine, flagged, revealed)
+//
+// The payload is base64 encoded and prefixed with GRID_STATE_MARKER so it can be stored in a text column.
+func EncodeGameGrid(grid [][]Cell) string {
+	gridSize := len(grid)
+
+	payload := make([]byte, 1, 1+binary.MaxVarintLen64+packedCellsLen(gridSize))
+	payload[0] = GRID_FORMAT_PACKED
+	payload = binary.AppendUvarint(payload, uint64(gridSize))
+
+	cells := make([]byte, packedCellsLen(gridSize))
+	for i, row := range grid {
+		for j, cell := range row {
+			var bits byte
+			if cell.HasMine {
+				bits |= CELL_BIT_MINE
+			}
+			if cell.IsFlagged {
+				bits |= CELL_BIT_FLAGGED
+			}
+			if cell.IsRevealed {
+				bits |= CELL_BIT_REVEALED
+			}
+
+			setPackedCell(cells, i*gridSize+j, bits)
+		}
+	}
+	payload = append(payload, cells...)
+
+	return string(GRID_STATE_MARKER) + base64.RawStdEncoding.EncodeToString(payload)
+}
+
+func packedCellsLen(gridSize int) int {
+	return (gridSize*gridSize*BITS_PER_CELL + 7) / 8
+}
+
+func setPackedCell(cells []byte, index int, bits byte) {
+	for b := 0; b < BITS_PER_CELL; b++ {
+		if bits&(1<<b) != 0 {
+			bit := index*BITS_PER_CELL + b
+			cells[bit/8] |= 1 << (bit % 8)
+		}
+	}
+}
+
+func getPackedCell(cells []byte, index int) byte {
+	var bits byte
+	for b := 0; b < BITS_PER_CELL; b++ {
+		bit := index*BITS_PER_CELL + b
+		if cells[bit/8]&(1<<(bit%8)) != 0 {
+			bits |= 1 << b
+		}
+	}
+	return bits
+}
+
+// encodeTextGameGrid encodes a game grid in the legacy GRID_FORMAT_TEXT format.
 // The encoding is as follows:
 //
 // - 'R' for a revealed cell
@@ -200,7 +458,9 @@ const (
 // - 'E' for a non-revealed cell without a mine
 //
 // The rows are separated by '|' characters.
-func EncodeGameGrid(grid [][]Cell) string {
+//
+// New grid states are never written in this format, it is kept so tests can produce legacy rows.
+func encodeTextGameGrid(grid [][]Cell) string {
 	var sb strings.Builder
 
 	for _, row := range grid {
@@ -228,24 +488,124 @@ func EncodeGameGrid(grid [][]Cell) string {
 //
 // The decoding is the reverse of EncodeGameGrid, i.e. it takes the string
 // representation of the game grid and returns a 2D slice of Cell structs.
+// Grid states written in the legacy GRID_FORMAT_TEXT format are still accepted.
+//
+// The input is validated against gridSize, any mismatch, unknown cell or truncated
+// payload is reported as a *GridStateError wrapping ErrCorruptGridState.
 //
 // Also calculates the number of adjacent mines for each cell in the grid with call to updateAdjacentMines.
-func DecodeGameGrid(encodedGameGrid string, gridSize int) [][]Cell {
-	rows := strings.Split(encodedGameGrid, "|")
-	decodedGameGrid := make([][]Cell, gridSize)
+func DecodeGameGrid(encodedGameGrid string, gridSize int) ([][]Cell, error) {
+	if gridSize < 1 {
+		return nil, gridStateError(GRID_FORMAT_TEXT, "invalid grid size %d", gridSize)
+	}
 
 	if len(encodedGameGrid) == 0 {
-		panic("encodedGameGrid == 0")
+		return nil, gridStateError(GRID_FORMAT_TEXT, "grid state is empty")
+	}
+
+	var (
+		decodedGameGrid [][]Cell
+		err             error
+	)
+
+	if strings.HasPrefix(encodedGameGrid, string(GRID_STATE_MARKER)) {
+		decodedGameGrid, err = decodePackedGameGrid(encodedGameGrid, gridSize)
+	} else {
+		decodedGameGrid, err = decodeTextGameGrid(encodedGameGrid, gridSize)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for row := 0; row < gridSize; row++ {
+		for col := 0; col < gridSize; col++ {
+			if decodedGameGrid[row][col].HasMine {
+				updateAdjacentCells(decodedGameGrid, row, col, gridSize)
+			}
+		}
 	}
 
+	return decodedGameGrid, nil
+}
+
+func decodePackedGameGrid(encodedGameGrid string, gridSize int) ([][]Cell, error) {
+	payload, err := base64.RawStdEncoding.DecodeString(encodedGameGrid[1:])
+	if err != nil {
+		return nil, gridStateError(GRID_FORMAT_PACKED, "invalid base64 payload: %v", err)
+	}
+
+	if len(payload) == 0 {
+		return nil, gridStateError(GRID_FORMAT_PACKED, "payload is empty")
+	}
+
+	if payload[0] != GRID_FORMAT_PACKED {
+		return nil, gridStateError(payload[0], "unsupported grid format version")
+	}
+
+	encodedSize, n := binary.Uvarint(payload[1:])
+	if n <= 0 {
+		return nil, gridStateError(GRID_FORMAT_PACKED, "invalid grid size header")
+	}
+
+	if encodedSize != uint64(gridSize) {
+		return nil, gridStateError(GRID_FORMAT_PACKED, "grid size %d does not match expected %d", encodedSize, gridSize)
+	}
+
+	cells := payload[1+n:]
+	if len(cells) != packedCellsLen(gridSize) {
+		return nil, gridStateError(GRID_FORMAT_PACKED, "expected %d bytes of cells, got %d", packedCellsLen(gridSize), len(cells))
+	}
+
+	// the unused bits of the last byte are always written as zeros
+	if usedBits := gridSize * gridSize * BITS_PER_CELL % 8; usedBits != 0 && cells[len(cells)-1]>>usedBits != 0 {
+		return nil, gridStateError(GRID_FORMAT_PACKED, "non-zero padding bits")
+	}
+
+	decodedGameGrid := make([][]Cell, gridSize)
+	for i := range decodedGameGrid {
+		decodedGameGrid[i] = make([]Cell, gridSize)
+
+		for j := range decodedGameGrid[i] {
+			bits := getPackedCell(cells, i*gridSize+j)
+
+			decodedGameGrid[i][j] = Cell{
+				HasMine:    bits&CELL_BIT_MINE != 0,
+				IsFlagged:  bits&CELL_BIT_FLAGGED != 0,
+				IsRevealed: bits&CELL_BIT_REVEALED != 0,
+			}
+		}
+	}
+
+	return decodedGameGrid, nil
+}
+
+func decodeTextGameGrid(encodedGameGrid string, gridSize int) ([][]Cell, error) {
+	// every row, including the last one, is terminated by a separator
+	if encodedGameGrid[len(encodedGameGrid)-1] != ROW_SEPARATOR {
+		return nil, gridStateError(GRID_FORMAT_TEXT, "missing trailing row separator")
+	}
+
+	rows := strings.Split(encodedGameGrid[:len(encodedGameGrid)-1], string(ROW_SEPARATOR))
+	if len(rows) != gridSize {
+		return nil, gridStateError(GRID_FORMAT_TEXT, "expected %d rows, got %d", gridSize, len(rows))
+	}
+
+	decodedGameGrid := make([][]Cell, gridSize)
+
 	for i, row := range rows {
-		if len(row) == 0 {
-			break
+		if len(row) != gridSize {
+			return nil, &GridStateError{
+				Format: GRID_FORMAT_TEXT,
+				Row:    i,
+				Col:    -1,
+				Reason: fmt.Sprintf("expected %d cells, got %d", gridSize, len(row)),
+			}
 		}
 
 		decodedGameGrid[i] = make([]Cell, gridSize)
 
-		for j, char := range row {
+		for j, char := range []byte(row) {
 			switch char {
 			case CELL_REVEALED:
 				decodedGameGrid[i][j] = Cell{IsRevealed: true}
@@ -257,33 +617,44 @@ func DecodeGameGrid(encodedGameGrid string, gridSize int) [][]Cell {
 				decodedGameGrid[i][j] = Cell{HasMine: true}
 			case CELL_EMPTY:
 				decodedGameGrid[i][j] = Cell{AdjacentMines: 0}
+			default:
+				return nil, &GridStateError{
+					Format: GRID_FORMAT_TEXT,
+					Row:    i,
+					Col:    j,
+					Reason: fmt.Sprintf("unknown cell %q", char),
+				}
 			}
 		}
 	}
 
-	for row := 0; row < gridSize; row++ {
-		for col := 0; col < gridSize; col++ {
-			if decodedGameGrid[row][col].HasMine {
-				updateAdjacentCells(decodedGameGrid, row, col, gridSize)
-			}
-		}
-	}
-
-	return decodedGameGrid
+	return decodedGameGrid, nil
 }
 
 func FromDbGame(dbGame *db.Game) (*Game, error) {
-	decodedGameGrid := DecodeGameGrid(dbGame.GridState, int(dbGame.GridSize))
+	decodedGameGrid, err := DecodeGameGrid(dbGame.GridState, int(dbGame.GridSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode grid of game %s: %w", dbGame.Uuid, err)
+	}
 
-	return &Game{
+	winRule, err := ParseWinRule(dbGame.WinRule)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load game %s: %w", dbGame.Uuid, err)
+	}
+
+	game := &Game{
 		Id:          dbGame.Id,
 		Uuid:        dbGame.Uuid,
 		GridSize:    int(dbGame.GridSize),
 		MinesAmount: int(dbGame.MinesAmount),
+		WinRule:     winRule,
 		Grid:        decodedGameGrid,
 		GameFailed:  dbGame.GameFailed,
 		GameWon:     dbGame.GameWon,
-	}, nil
+	}
+	game.recountCells()
+
+	return game, nil
 }
 
 func ToDbGame(game *Game) (*db.Game, error) {
@@ -1,11 +1,12 @@
 package models
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 )
 
-func TestEncodeGameGrid(t *testing.T) {
+func TestEncodeTextGameGrid(t *testing.T) {
 	testCases := []struct {
 		name     string
 		grid     [][]Cell
@@ -33,7 +34,7 @@ func TestEncodeGameGrid(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			encodedGameGrid := EncodeGameGrid(tc.grid)
+			encodedGameGrid := encodeTextGameGrid(tc.grid)
 			if encodedGameGrid != tc.expected {
 				t.Errorf("Test case '%s' failed. Expected encoded grid to be '%s', but got '%s'", tc.name, tc.expected, encodedGameGrid)
 			}
@@ -70,10 +71,181 @@ func TestDecodeGameGrid(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			decodedGameGrid := DecodeGameGrid(tc.encodedString, tc.gridSize)
+			decodedGameGrid, err := DecodeGameGrid(tc.encodedString, tc.gridSize)
+			if err != nil {
+				t.Fatalf("Test case '%s' failed. Unexpected error: %v", tc.name, err)
+			}
 			if !reflect.DeepEqual(decodedGameGrid, tc.expected) {
 				t.Errorf("Test case '%s' failed. Expected decoded grid to be '%v', but got '%v'", tc.name, tc.expected, decodedGameGrid)
 			}
 		})
 	}
 }
+
+func TestEncodeDecodePackedGameGrid(t *testing.T) {
+	testCases := []struct {
+		name string
+		grid [][]Cell
+	}{
+		{
+			name: "Simple grid with mine, revealed, flagged, empty",
+			grid: [][]Cell{
+				{{HasMine: true, AdjacentMines: 1}, {IsRevealed: true, AdjacentMines: 2}},
+				{{IsFlagged: true, AdjacentMines: 2}, {HasMine: true, AdjacentMines: 1}},
+			},
+		},
+		{
+			name: "Revealed mine keeps its mine",
+			grid: [][]Cell{
+				{{HasMine: true, IsRevealed: true}, {AdjacentMines: 1}},
+				{{AdjacentMines: 1}, {IsRevealed: true, AdjacentMines: 1}},
+			},
+		},
+		{
+			name: "Single cell grid",
+			grid: [][]Cell{
+				{{IsFlagged: true, HasMine: true}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			encodedGameGrid := EncodeGameGrid(tc.grid)
+
+			if format := GridStateFormat(encodedGameGrid); format != GRID_FORMAT_PACKED {
+				t.Fatalf("Test case '%s' failed. Expected format %d, but got %d", tc.name, GRID_FORMAT_PACKED, format)
+			}
+
+			decodedGameGrid, err := DecodeGameGrid(encodedGameGrid, len(tc.grid))
+			if err != nil {
+				t.Fatalf("Test case '%s' failed. Unexpected error: %v", tc.name, err)
+			}
+			if !reflect.DeepEqual(decodedGameGrid, tc.grid) {
+				t.Errorf("Test case '%s' failed. Expected decoded grid to be '%v', but got '%v'", tc.name, tc.grid, decodedGameGrid)
+			}
+		})
+	}
+}
+
+func TestGridStateFormat(t *testing.T) {
+	testCases := []struct {
+		name          string
+		encodedString string
+		expected      byte
+	}{
+		{name: "Legacy text grid", encodedString: "MR|EM|", expected: GRID_FORMAT_TEXT},
+		{name: "Packed grid", encodedString: EncodeGameGrid(NewGame(5, 3, WinRuleClassic).Grid), expected: GRID_FORMAT_PACKED},
+		{name: "Marker with invalid payload", encodedString: "~!!", expected: GRID_FORMAT_TEXT},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if format := GridStateFormat(tc.encodedString); format != tc.expected {
+				t.Errorf("Test case '%s' failed. Expected format %d, but got %d", tc.name, tc.expected, format)
+			}
+		})
+	}
+}
+
+func TestDecodeGameGridCorruptState(t *testing.T) {
+	validPacked := EncodeGameGrid(NewGame(3, 2, WinRuleClassic).Grid)
+
+	testCases := []struct {
+		name          string
+		encodedString string
+		gridSize      int
+	}{
+		{name: "Empty input", encodedString: "", gridSize: 2},
+		{name: "Invalid grid size", encodedString: "MR|EM|", gridSize: 0},
+		{name: "Missing trailing separator", encodedString: "MR|EM", gridSize: 2},
+		{name: "Too few rows", encodedString: "MR|", gridSize: 2},
+		{name: "Too many rows", encodedString: "MR|EM|EE|", gridSize: 2},
+		{name: "Row too short", encodedString: "MR|E|", gridSize: 2},
+		{name: "Row too long", encodedString: "MRE|EM|", gridSize: 2},
+		{name: "Unknown cell", encodedString: "MR|EZ|", gridSize: 2},
+		{name: "Packed with invalid base64", encodedString: "~!!", gridSize: 2},
+		{name: "Packed with empty payload", encodedString: "~", gridSize: 2},
+		{name: "Packed with unknown version", encodedString: "~CQI", gridSize: 2},
+		{name: "Packed with mismatched grid size", encodedString: validPacked, gridSize: 4},
+		{name: "Packed with truncated cells", encodedString: validPacked[:len(validPacked)-2], gridSize: 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			decodedGameGrid, err := DecodeGameGrid(tc.encodedString, tc.gridSize)
+			if err == nil {
+				t.Fatalf("Test case '%s' failed. Expected an error, but got grid '%v'", tc.name, decodedGameGrid)
+			}
+
+			if !errors.Is(err, ErrCorruptGridState) {
+				t.Errorf("Test case '%s' failed. Expected ErrCorruptGridState, but got '%v'", tc.name, err)
+			}
+
+			var gridStateErr *GridStateError
+			if !errors.As(err, &gridStateErr) {
+				t.Errorf("Test case '%s' failed. Expected a *GridStateError, but got %T", tc.name, err)
+			}
+		})
+	}
+}
+
+func TestCheckWinCondition(t *testing.T) {
+	// a single mine in the top left corner, every other cell is safe
+	newTestGame := func(winRule WinRule) *Game {
+		grid, err := DecodeGameGrid("ME|EE|", 2)
+		if err != nil {
+			t.Fatalf("Failed to decode test grid: %v", err)
+		}
+		game := &Game{GridSize: 2, MinesAmount: 1, WinRule: winRule, Grid: grid}
+		game.recountCells()
+		return game
+	}
+
+	testCases := []struct {
+		name        string
+		winRule     WinRule
+		flagMine    bool
+		flagSafe    bool
+		expectedWon bool
+	}{
+		{name: "Classic wins without flags", winRule: WinRuleClassic, expectedWon: true},
+		{name: "Classic wins with flagged mine", winRule: WinRuleClassic, flagMine: true, expectedWon: true},
+		{name: "Strict requires flagged mine", winRule: WinRuleStrict, expectedWon: false},
+		{name: "Strict wins with flagged mine", winRule: WinRuleStrict, flagMine: true, expectedWon: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			game := newTestGame(tc.winRule)
+
+			if tc.flagMine {
+				game.FlagCell(0, 0)
+			}
+			game.RevealCell(0, 1)
+			game.RevealCell(1, 0)
+			game.RevealCell(1, 1)
+
+			if game.GameWon != tc.expectedWon {
+				t.Fatalf("Test case '%s' failed. Expected GameWon to be %v, but got %v", tc.name, tc.expectedWon, game.GameWon)
+			}
+
+			if game.GameWon && !game.Grid[0][0].IsFlagged {
+				t.Errorf("Test case '%s' failed. Expected the mine to be flagged once the game is won", tc.name)
+			}
+		})
+	}
+}
+
+func TestParseWinRule(t *testing.T) {
+	for _, rule := range []WinRule{WinRuleClassic, WinRuleStrict} {
+		parsed, err := ParseWinRule(string(rule))
+		if err != nil || parsed != rule {
+			t.Errorf("Expected rule '%s' to parse, but got '%s' and error '%v'", rule, parsed, err)
+		}
+	}
+
+	if _, err := ParseWinRule("lenient"); err == nil {
+		t.Errorf("Expected an error for an unknown rule")
+	}
+}
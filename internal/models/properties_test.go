@@ -0,0 +1,272 @@
+package models
+
+import (
+	"math/rand"
+	"testing"
+)
+
+const propertyRuns = 200
+
+// gridFromBytes builds a gridSize x gridSize grid using one byte of data per cell,
+// the low bits are interpreted the same way as in the packed format.
+func gridFromBytes(gridSize int, data []byte) [][]Cell {
+	grid := make([][]Cell, gridSize)
+
+	for i := range grid {
+		grid[i] = make([]Cell, gridSize)
+		for j := range grid[i] {
+			var bits byte
+			if index := i*gridSize + j; index < len(data) {
+				bits = data[index]
+			}
+
+			grid[i][j] = Cell{
+				HasMine:    bits&CELL_BIT_MINE != 0,
+				IsFlagged:  bits&CELL_BIT_FLAGGED != 0,
+				IsRevealed: bits&CELL_BIT_REVEALED != 0,
+			}
+		}
+	}
+
+	for row := range grid {
+		for col := range grid[row] {
+			if grid[row][col].HasMine {
+				updateAdjacentCells(grid, row, col, gridSize)
+			}
+		}
+	}
+
+	return grid
+}
+
+func sameCells(a, b [][]Cell) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if len(a[i]) != len(b[i]) {
+			return false
+		}
+		for j := range a[i] {
+			if a[i][j] != b[i][j] {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func countCells(grid [][]Cell, match func(Cell) bool) int {
+	count := 0
+	for _, row := range grid {
+		for _, cell := range row {
+			if match(cell) {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func FuzzEncodeDecodeGameGrid(f *testing.F) {
+	f.Add(uint8(1), []byte{CELL_BIT_MINE})
+	f.Add(uint8(2), []byte{CELL_BIT_MINE, CELL_BIT_REVEALED, CELL_BIT_FLAGGED, 0})
+	f.Add(uint8(3), []byte{7, 6, 5, 4, 3, 2, 1, 0, 7})
+	f.Add(uint8(22), []byte{})
+
+	f.Fuzz(func(t *testing.T, size uint8, data []byte) {
+		gridSize := int(size)%32 + 1
+		grid := gridFromBytes(gridSize, data)
+
+		decodedGameGrid, err := DecodeGameGrid(EncodeGameGrid(grid), gridSize)
+		if err != nil {
+			t.Fatalf("Failed to decode freshly encoded grid of size %d: %v", gridSize, err)
+		}
+
+		if !sameCells(grid, decodedGameGrid) {
+			t.Fatalf("Round trip changed the grid. Expected '%v', but got '%v'", grid, decodedGameGrid)
+		}
+	})
+}
+
+func FuzzDecodeGameGrid(f *testing.F) {
+	f.Add("MR|EM|", 2)
+	f.Add("XX|XX|", 2)
+	f.Add("MR|E|", 2)
+	f.Add("~AQIhAg", 2)
+	f.Add("~", 1)
+	f.Add("", 3)
+
+	f.Fuzz(func(t *testing.T, encodedGameGrid string, gridSize int) {
+		if gridSize > 64 {
+			t.Skip()
+		}
+
+		decodedGameGrid, err := DecodeGameGrid(encodedGameGrid, gridSize)
+		if err != nil {
+			return
+		}
+
+		// whatever was accepted has to survive a round trip through the current format
+		reDecodedGameGrid, err := DecodeGameGrid(EncodeGameGrid(decodedGameGrid), gridSize)
+		if err != nil {
+			t.Fatalf("Failed to decode re-encoded grid: %v", err)
+		}
+
+		if !sameCells(decodedGameGrid, reDecodedGameGrid) {
+			t.Fatalf("Re-encoding changed the grid. Expected '%v', but got '%v'", decodedGameGrid, reDecodedGameGrid)
+		}
+	})
+}
+
+// playRandomMoves plays random reveal and flag moves until the game ends or the moves run out,
+// calling check after every move.
+func playRandomMoves(rng *rand.Rand, game *Game, moves int, check func(move int)) {
+	for move := 0; move < moves && !game.GameFailed && !game.GameWon; move++ {
+		row, col := rng.Intn(game.GridSize), rng.Intn(game.GridSize)
+
+		if rng.Intn(4) == 0 {
+			game.FlagCell(row, col)
+		} else {
+			game.RevealCell(row, col)
+		}
+
+		check(move)
+	}
+}
+
+func randomGame(rng *rand.Rand) *Game {
+	gridSize := rng.Intn(12) + 2
+	minesAmount := rng.Intn(gridSize*gridSize-1) + 1
+
+	winRule := WinRuleClassic
+	if rng.Intn(2) == 0 {
+		winRule = WinRuleStrict
+	}
+
+	return NewGame(gridSize, minesAmount, winRule)
+}
+
+func TestPropertyMineCountNeverChanges(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for run := 0; run < propertyRuns; run++ {
+		game := randomGame(rng)
+
+		playRandomMoves(rng, game, 100, func(move int) {
+			mines := countCells(game.Grid, func(c Cell) bool { return c.HasMine })
+			if mines != game.MinesAmount {
+				t.Fatalf("Run %d, move %d: expected %d mines, but got %d", run, move, game.MinesAmount, mines)
+			}
+		})
+	}
+}
+
+func TestPropertyFloodFillNeverRevealsMine(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+
+	for run := 0; run < propertyRuns; run++ {
+		game := randomGame(rng)
+
+		for move := 0; move < 100 && !game.GameFailed && !game.GameWon; move++ {
+			row, col := rng.Intn(game.GridSize), rng.Intn(game.GridSize)
+			if game.Grid[row][col].HasMine {
+				continue
+			}
+
+			game.RevealCell(row, col)
+
+			if revealedMines := countCells(game.Grid, func(c Cell) bool { return c.HasMine && c.IsRevealed }); revealedMines != 0 {
+				t.Fatalf("Run %d, move %d: revealing safe cell %d,%d revealed %d mines", run, move, row, col, revealedMines)
+			}
+
+			if game.GameFailed {
+				t.Fatalf("Run %d, move %d: revealing safe cell %d,%d failed the game", run, move, row, col)
+			}
+		}
+	}
+}
+
+func TestPropertyWonGameHasNoUnrevealedSafeCells(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+
+	for run := 0; run < propertyRuns; run++ {
+		game := randomGame(rng)
+
+		// reveal every safe cell and flag every mine, in random order
+		cells := rng.Perm(game.GridSize * game.GridSize)
+		for _, index := range cells {
+			row, col := index/game.GridSize, index%game.GridSize
+			if game.Grid[row][col].HasMine {
+				game.FlagCell(row, col)
+			} else {
+				game.RevealCell(row, col)
+			}
+		}
+
+		if !game.GameWon || !game.CheckWinCondition() {
+			t.Fatalf("Run %d: expected the game to be won after clearing the board", run)
+		}
+
+		if unrevealed := countCells(game.Grid, func(c Cell) bool { return !c.HasMine && !c.IsRevealed }); unrevealed != 0 {
+			t.Fatalf("Run %d: won game has %d unrevealed safe cells", run, unrevealed)
+		}
+	}
+}
+
+func TestPropertyWinConditionImpliesClearedBoard(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+
+	for run := 0; run < propertyRuns; run++ {
+		game := randomGame(rng)
+
+		playRandomMoves(rng, game, 200, func(move int) {
+			if !game.GameWon {
+				return
+			}
+
+			if unrevealed := countCells(game.Grid, func(c Cell) bool { return !c.HasMine && !c.IsRevealed }); unrevealed != 0 {
+				t.Fatalf("Run %d, move %d: won game has %d unrevealed safe cells", run, move, unrevealed)
+			}
+		})
+	}
+}
+
+func TestPropertyGameSurvivesEncoding(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+
+	for run := 0; run < propertyRuns; run++ {
+		game := randomGame(rng)
+
+		playRandomMoves(rng, game, 50, func(move int) {
+			decodedGameGrid, err := DecodeGameGrid(EncodeGameGrid(game.Grid), game.GridSize)
+			if err != nil {
+				t.Fatalf("Run %d, move %d: failed to decode grid: %v", run, move, err)
+			}
+
+			if !sameCells(game.Grid, decodedGameGrid) {
+				t.Fatalf("Run %d, move %d: round trip changed the grid", run, move)
+			}
+		})
+	}
+}
+
+func TestPropertyCountersMatchGrid(t *testing.T) {
+	rng := rand.New(rand.NewSource(6))
+
+	for run := 0; run < propertyRuns; run++ {
+		game := randomGame(rng)
+
+		playRandomMoves(rng, game, 100, func(move int) {
+			revealed, flagged, flaggedMines := game.RevealedCells, game.FlaggedCells, game.flaggedMines
+			game.recountCells()
+
+			if revealed != game.RevealedCells || flagged != game.FlaggedCells || flaggedMines != game.flaggedMines {
+				t.Fatalf("Run %d, move %d: counters drifted, got revealed %d flagged %d flagged mines %d, expected %d %d %d",
+					run, move, revealed, flagged, flaggedMines, game.RevealedCells, game.FlaggedCells, game.flaggedMines)
+			}
+		})
+	}
+}
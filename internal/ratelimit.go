@@ -0,0 +1,291 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"golang.org/x/time/rate"
+)
+
+// DefaultRoute names the limits of the routes that have none of their own.
+const DefaultRoute = "*"
+
+// Rate allows Requests requests per Period. A client may send all of them at once, after that they are
+// allowed back one by one as the period goes by (a token bucket). The zero Rate allows everything.
+type Rate struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Unlimited tells whether the rate allows everything.
+func (r Rate) Unlimited() bool {
+	return r.Requests <= 0 || r.Period <= 0
+}
+
+func (r Rate) String() string {
+	if r.Unlimited() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%v", r.Requests, r.Period)
+}
+
+// ParseRate parses a rate like 10/m, 5/s or 100/10m, off disables the limit.
+func ParseRate(value string) (Rate, error) {
+	if value == "off" {
+		return Rate{}, nil
+	}
+
+	requestsStr, periodStr, ok := strings.Cut(value, "/")
+	if !ok {
+		return Rate{}, fmt.Errorf("rate %q must look like 10/m", value)
+	}
+
+	requests, err := strconv.Atoi(requestsStr)
+	if err != nil || requests < 1 {
+		return Rate{}, fmt.Errorf("rate %q must allow at least one request", value)
+	}
+
+	// a bare unit is a single one of it, 10/m is 10/1m
+	if periodStr != "" && (periodStr[0] < '0' || periodStr[0] > '9') {
+		periodStr = "1" + periodStr
+	}
+	period, err := time.ParseDuration(periodStr)
+	if err != nil || period <= 0 {
+		return Rate{}, fmt.Errorf("rate %q must have a positive period like s, m or 10m", value)
+	}
+
+	return Rate{Requests: requests, Period: period}, nil
+}
+
+// RouteLimit limits the requests of a route per client IP and per session.
+type RouteLimit struct {
+	PerIP      Rate
+	PerSession Rate
+}
+
+// RateLimitConfig configures the RateLimiter.
+type RateLimitConfig struct {
+	// Routes maps route patterns, like "POST /games", to their limits. The DefaultRoute limits apply to
+	// every other route, their requests share a single budget.
+	Routes map[string]RouteLimit
+	// TrustForwardedFor takes the client IP from the last X-Forwarded-For entry. Only enable it behind a
+	// proxy that sets the header, anybody else can send whatever they like in it.
+	TrustForwardedFor bool
+}
+
+// DefaultRateLimitConfig returns limits a player does not notice, but that keep a script from creating
+// games in a loop.
+func DefaultRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		Routes: map[string]RouteLimit{
+			"POST /games": {
+				PerIP:      Rate{Requests: 30, Period: time.Minute},
+				PerSession: Rate{Requests: 10, Period: time.Minute},
+			},
+			"POST /games/{uuid}/moves": {
+				PerIP:      Rate{Requests: 600, Period: time.Minute},
+				PerSession: Rate{Requests: 300, Period: time.Minute},
+			},
+			DefaultRoute: {
+				PerIP: Rate{Requests: 600, Period: time.Minute},
+			},
+		},
+	}
+}
+
+// ParseRouteLimits parses the limits of routes, like
+//
+//	POST /games=ip:30/m session:10/m; *=ip:600/m
+//
+// A route without an ip or session rate is not limited by it.
+func ParseRouteLimits(value string) (map[string]RouteLimit, error) {
+	routes := make(map[string]RouteLimit)
+
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		route, ratesStr, ok := strings.Cut(entry, "=")
+		route = strings.TrimSpace(route)
+		if !ok || route == "" {
+			return nil, fmt.Errorf("limit %q must look like ROUTE=ip:RATE session:RATE", entry)
+		}
+
+		var limit RouteLimit
+		for _, rateStr := range strings.Fields(ratesStr) {
+			scope, value, _ := strings.Cut(rateStr, ":")
+			parsedRate, err := ParseRate(value)
+			if err != nil {
+				return nil, fmt.Errorf("route %s: %w", route, err)
+			}
+
+			switch scope {
+			case "ip":
+				limit.PerIP = parsedRate
+			case "session":
+				limit.PerSession = parsedRate
+			default:
+				return nil, fmt.Errorf("route %s: unknown scope %q, expected ip or session", route, scope)
+			}
+		}
+
+		routes[route] = limit
+	}
+
+	return routes, nil
+}
+
+// bucketIdleTimeout is how long a bucket is kept at least after its last request.
+const bucketIdleTimeout = time.Minute
+
+type bucketKey struct {
+	route  string
+	scope  string
+	client string
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+	// refill is the time an empty bucket takes to be full again, a bucket idle for longer than that
+	// is the same as a new one and can be dropped
+	refill time.Duration
+}
+
+// RateLimiter keeps a token bucket per route, scope and client. It is safe for concurrent use.
+type RateLimiter struct {
+	config RateLimitConfig
+	now    func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+}
+
+func NewRateLimiter(config RateLimitConfig) *RateLimiter {
+	return &RateLimiter{
+		config:  config,
+		now:     time.Now,
+		buckets: make(map[bucketKey]*bucket),
+	}
+}
+
+// Allow takes a request of the client from the bucket of route and scope. It returns zero when the request
+// is allowed, or how long the client has to wait for the next one.
+func (l *RateLimiter) Allow(route string, scope string, client string, limit Rate) time.Duration {
+	if limit.Unlimited() {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	key := bucketKey{route: route, scope: scope, client: client}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{
+			limiter: rate.NewLimiter(rate.Limit(float64(limit.Requests)/limit.Period.Seconds()), limit.Requests),
+			refill:  limit.Period,
+		}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+
+	reservation := b.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		// the request is rejected, so it must not use up a token of the future
+		reservation.CancelAt(now)
+		return delay
+	}
+	return 0
+}
+
+// sweep drops the buckets that are full again, so clients that left do not pile up. It runs at most
+// once every bucketIdleTimeout.
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < bucketIdleTimeout {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) > max(b.refill, bucketIdleTimeout) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// limit returns the limits of route and the name of the budget it is counted in.
+func (l *RateLimiter) limit(route string) (string, RouteLimit) {
+	if limit, ok := l.config.Routes[route]; ok {
+		return route, limit
+	}
+	return DefaultRoute, l.config.Routes[DefaultRoute]
+}
+
+// clientIP returns the IP the request came from, see RateLimitConfig.TrustForwardedFor.
+func (l *RateLimiter) clientIP(r *http.Request) string {
+	if l.config.TrustForwardedFor {
+		if values := r.Header.Values("X-Forwarded-For"); len(values) > 0 {
+			// the last entry is the one added by the proxy, the ones before it come from the client
+			entries := strings.Split(values[len(values)-1], ",")
+			if ip := strings.TrimSpace(entries[len(entries)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+var errRateLimited = errors.New("rate limit exceeded")
+
+// RateLimit rejects the requests over the limits of their route with 429 Too Many Requests and a
+// Retry-After header. routes is only used to find the pattern of the route a request is for.
+//
+// Requests are limited per client IP, and per session once the session has an ID. A request that is
+// over the IP limit is not counted against the session limit.
+func RateLimit(limiter *RateLimiter, store *sessions.CookieStore, templates *template.Template, routes *http.ServeMux) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, pattern := routes.Handler(r)
+			route, limit := limiter.limit(pattern)
+
+			retryAfter := limiter.Allow(route, "ip", limiter.clientIP(r), limit.PerIP)
+			if retryAfter == 0 {
+				if sessionID := SessionID(r, store); sessionID != "" {
+					retryAfter = limiter.Allow(route, "session", sessionID, limit.PerSession)
+				}
+			}
+
+			if retryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				writeError(w, r, templates, TooManyRequests(
+					"Too many requests, please wait a moment and try again.",
+					fmt.Errorf("%w for %s", errRateLimited, route),
+				))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
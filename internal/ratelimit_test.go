@@ -0,0 +1,183 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	testCases := []struct {
+		value       string
+		expected    Rate
+		expectedErr bool
+	}{
+		{"10/m", Rate{Requests: 10, Period: time.Minute}, false},
+		{"5/s", Rate{Requests: 5, Period: time.Second}, false},
+		{"100/10m", Rate{Requests: 100, Period: 10 * time.Minute}, false},
+		{"off", Rate{}, false},
+		{"10", Rate{}, true},
+		{"0/m", Rate{}, true},
+		{"ten/m", Rate{}, true},
+		{"10/fortnight", Rate{}, true},
+		{"10/-1m", Rate{}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			parsed, err := ParseRate(tc.value)
+			if tc.expectedErr {
+				if err == nil {
+					t.Errorf("Expected an error, but got %v", parsed)
+				}
+				return
+			}
+			if err != nil || parsed != tc.expected {
+				t.Errorf("Expected %v, but got %v (error %v)", tc.expected, parsed, err)
+			}
+		})
+	}
+}
+
+func TestParseRouteLimits(t *testing.T) {
+	routes, err := ParseRouteLimits("POST /games=ip:30/m session:10/m; *=ip:off")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[string]RouteLimit{
+		"POST /games": {PerIP: Rate{Requests: 30, Period: time.Minute}, PerSession: Rate{Requests: 10, Period: time.Minute}},
+		DefaultRoute:  {},
+	}
+	if len(routes) != len(expected) {
+		t.Fatalf("Expected %d routes, but got %v", len(expected), routes)
+	}
+	for route, limit := range expected {
+		if routes[route] != limit {
+			t.Errorf("Expected the limits of %s to be %+v, but got %+v", route, limit, routes[route])
+		}
+	}
+
+	for _, invalid := range []string{"POST /games", "=ip:1/m", "POST /games=user:1/m", "POST /games=ip:1"} {
+		if _, err := ParseRouteLimits(invalid); err == nil {
+			t.Errorf("Expected %q to be invalid", invalid)
+		}
+	}
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	now := time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(RateLimitConfig{})
+	limiter.now = func() time.Time { return now }
+
+	limit := Rate{Requests: 2, Period: time.Second}
+
+	for i := 0; i < 2; i++ {
+		if delay := limiter.Allow("POST /games", "ip", "192.0.2.1", limit); delay != 0 {
+			t.Fatalf("Expected request %d of the burst to be allowed, but got a delay of %v", i+1, delay)
+		}
+	}
+	if delay := limiter.Allow("POST /games", "ip", "192.0.2.1", limit); delay != 500*time.Millisecond {
+		t.Errorf("Expected to wait for the next token, but got a delay of %v", delay)
+	}
+	if delay := limiter.Allow("POST /games", "ip", "192.0.2.2", limit); delay != 0 {
+		t.Errorf("Expected another client to have its own bucket, but got a delay of %v", delay)
+	}
+
+	// the rejected request did not use up the next token
+	now = now.Add(500 * time.Millisecond)
+	if delay := limiter.Allow("POST /games", "ip", "192.0.2.1", limit); delay != 0 {
+		t.Errorf("Expected a request to be allowed once a token is back, but got a delay of %v", delay)
+	}
+
+	now = now.Add(2 * bucketIdleTimeout)
+	limiter.Allow("POST /games", "ip", "192.0.2.3", limit)
+	if len(limiter.buckets) != 1 {
+		t.Errorf("Expected the idle buckets to be dropped, but got %d buckets", len(limiter.buckets))
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	h := newTestHandler(t, newFakeGameService())
+	routes := Routes(h, NewApiHandler(h.Templates, h.Store, h.Queries))
+
+	limiter := NewRateLimiter(RateLimitConfig{
+		Routes: map[string]RouteLimit{
+			"GET /{$}": {
+				PerIP:      Rate{Requests: 3, Period: time.Minute},
+				PerSession: Rate{Requests: 1, Period: time.Minute},
+			},
+		},
+	})
+	app := RateLimit(limiter, h.Store, h.Templates, routes)(CSRF(h.Store, h.Templates)(routes))
+
+	serveFrom := func(remoteAddr string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.RemoteAddr = remoteAddr
+		request.Header.Set("HX-Request", "true")
+		if cookie != nil {
+			request.AddCookie(cookie)
+		}
+		recorder := httptest.NewRecorder()
+		app.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	firstSession, _ := csrfSession(t, h)
+	secondSession, _ := csrfSession(t, h)
+
+	testCases := []struct {
+		name           string
+		remoteAddr     string
+		cookie         *http.Cookie
+		expectedStatus int
+		// a token of the session comes back every minute, one of the IP every 20 seconds
+		expectedRetryAfter string
+	}{
+		{"First request of a session", "192.0.2.1:1234", firstSession, http.StatusOK, ""},
+		{"Session over its limit", "192.0.2.1:1234", firstSession, http.StatusTooManyRequests, "60"},
+		{"Another session of the same IP", "192.0.2.1:1234", secondSession, http.StatusOK, ""},
+		{"IP over its limit", "192.0.2.1:1234", nil, http.StatusTooManyRequests, "20"},
+		{"Another IP", "198.51.100.7:1234", nil, http.StatusOK, ""},
+	}
+
+	for _, tc := range testCases {
+		recorder := serveFrom(tc.remoteAddr, tc.cookie)
+
+		if recorder.Code != tc.expectedStatus {
+			t.Errorf("%s: expected status %d, but got %d", tc.name, tc.expectedStatus, recorder.Code)
+		}
+		if tc.expectedStatus != http.StatusTooManyRequests {
+			continue
+		}
+		if retryAfter := recorder.Header().Get("Retry-After"); retryAfter != tc.expectedRetryAfter {
+			t.Errorf("%s: expected to retry after %s seconds, but got %q", tc.name, tc.expectedRetryAfter, retryAfter)
+		}
+		if !strings.Contains(recorder.Body.String(), "Too many requests") {
+			t.Errorf("%s: expected the error message to be rendered, but got %s", tc.name, recorder.Body.String())
+		}
+	}
+
+	// routes without limits are not counted
+	request := httptest.NewRequest(http.MethodGet, "/dist/tailwind.css", nil)
+	recorder := httptest.NewRecorder()
+	app.ServeHTTP(recorder, request)
+	if recorder.Code == http.StatusTooManyRequests {
+		t.Errorf("Expected a route without limits to be served")
+	}
+}
+
+func TestRateLimiterClientIP(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.RemoteAddr = "10.0.0.2:4321"
+	request.Header.Add("X-Forwarded-For", "203.0.113.9, 198.51.100.7")
+
+	if ip := NewRateLimiter(RateLimitConfig{}).clientIP(request); ip != "10.0.0.2" {
+		t.Errorf("Expected the remote address without a trusted proxy, but got %s", ip)
+	}
+	if ip := NewRateLimiter(RateLimitConfig{TrustForwardedFor: true}).clientIP(request); ip != "198.51.100.7" {
+		t.Errorf("Expected the address added by the proxy, but got %s", ip)
+	}
+}
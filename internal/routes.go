@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// Routes registers every route of the application.
+//
+// Routes are bound to their method, the mux answers any other method with 405 Method Not Allowed.
+// Every route reports its pattern to Instrument. The routes of the admin area are guarded by AdminAuth.
+func Routes(handler *Handler, apiHandler *ApiHandler) *http.ServeMux {
+	mux := http.NewServeMux()
+	handle := func(pattern string, handlerFunc http.HandlerFunc) {
+		mux.Handle(pattern, withRoute(pattern, handlerFunc))
+	}
+	adminAuth := AdminAuth(handler.Admin, handler.Templates)
+	handleAdmin := func(pattern string, handlerFunc http.HandlerFunc) {
+		mux.Handle(pattern, withRoute(pattern, adminAuth(handlerFunc)))
+	}
+
+	staticFiles := http.StripPrefix("/dist/", http.FileServer(http.Dir("dist")))
+	mux.Handle("GET /dist/", withRoute("GET /dist/", staticFiles))
+
+	handle("GET /{$}", handler.Index)
+	handle("POST /games", handler.StartGame)
+	handle("GET /games/{uuid}", handler.LoadGame)
+	handle("POST /games/{uuid}/moves", handler.HandleGridAction)
+	handle("GET /session-games-info", handler.SessionGamesInfo)
+	handle("GET /session-games-export", handler.ExportSessionGames)
+
+	handleAdmin("GET /games", handler.IndexGames)
+	handleAdmin("GET /games/export", handler.ExportGames)
+	handleAdmin("POST /games/bulk", handler.BulkGames)
+	handleAdmin("GET /games/{uuid}/details", handler.GameDetails)
+	handleAdmin("POST /games/{uuid}/abandon", handler.AbandonGame)
+	handleAdmin("POST /games/{uuid}/clone", handler.CloneGame)
+	handleAdmin("POST /games/{uuid}/delete", handler.DeleteGame)
+	handleAdmin("GET /charts", handler.Charts)
+
+	handleAdmin("GET /api/charts/pie/wins-losses-incomplete", apiHandler.PieWinsLossesIncompleteChart)
+	handleAdmin("GET /api/charts/bar/grid-size", apiHandler.GridSizeBar)
+	handleAdmin("GET /api/charts/bar/mines-amount", apiHandler.MinesAmountBarChart)
+	handleAdmin("GET /api/charts/bar/games-played", apiHandler.PlayedGamesInMonthBarChart)
+
+	// routes used before the games became resources, kept so old pages and bookmarks keep working
+	handle("GET /load-game", handler.RedirectLoadGame)
+	handle("POST /start-game", handler.RedirectStartGame)
+	handle("POST /handle-grid-action", handler.RedirectGridAction)
+
+	return mux
+}
+
+// RedirectLoadGame redirects GET /load-game?game_uuid= to GET /games/{uuid}.
+func (h *Handler) RedirectLoadGame(w http.ResponseWriter, r *http.Request) {
+	gameUuid := r.URL.Query().Get("game_uuid")
+	if gameUuid == "" {
+		h.error(w, r, BadRequest("Missing game_uuid parameter.", nil))
+		return
+	}
+
+	http.Redirect(w, r, "/games/"+url.PathEscape(gameUuid), http.StatusMovedPermanently)
+}
+
+// RedirectStartGame redirects POST /start-game to POST /games, 308 keeps the method and the form.
+func (h *Handler) RedirectStartGame(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, "/games", http.StatusPermanentRedirect)
+}
+
+// RedirectGridAction redirects POST /handle-grid-action to the moves of the last game in the session.
+// It used to accept GET as well, that is no longer allowed as a move changes the game.
+func (h *Handler) RedirectGridAction(w http.ResponseWriter, r *http.Request) {
+	storedGamesUuids, err := GetGameFromSession(r, h.Store)
+	if err != nil || len(storedGamesUuids) == 0 {
+		h.error(w, r, Conflict("There is no game in progress, start a new game or load one first.", err))
+		return
+	}
+	lastGameUuid := storedGamesUuids[len(storedGamesUuids)-1]
+
+	target := url.URL{Path: "/games/" + lastGameUuid + "/moves", RawQuery: r.URL.RawQuery}
+	http.Redirect(w, r, target.String(), http.StatusPermanentRedirect)
+}
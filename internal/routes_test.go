@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"fmt"
+	"minesweeper/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRoutesRejectWrongMethods(t *testing.T) {
+	h := newTestHandler(t, newFakeGameService())
+
+	testCases := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodGet, "/start-game"},
+		{http.MethodGet, "/handle-grid-action?action=reveal_cell&row=0&col=0"},
+		{http.MethodGet, "/games/uuid-1/moves"},
+		{http.MethodDelete, "/games/uuid-1"},
+		{http.MethodPut, "/games"},
+		{http.MethodPost, "/"},
+		{http.MethodPost, "/session-games-info"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			recorder := serve(h, httptest.NewRequest(tc.method, tc.path, nil))
+
+			if recorder.Code != http.StatusMethodNotAllowed {
+				t.Errorf("Expected status %d, but got %d", http.StatusMethodNotAllowed, recorder.Code)
+			}
+			if recorder.Header().Get("Allow") == "" {
+				t.Errorf("Expected the allowed methods to be listed")
+			}
+		})
+	}
+}
+
+func TestLegacyRoutesRedirect(t *testing.T) {
+	h := newTestHandler(t, newFakeGameService())
+
+	testCases := []struct {
+		name             string
+		method           string
+		path             string
+		withSession      bool
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{"Load game", http.MethodGet, "/load-game?game_uuid=uuid-1", false, http.StatusMovedPermanently, "/games/uuid-1"},
+		{"Load game without uuid", http.MethodGet, "/load-game", false, http.StatusBadRequest, ""},
+		{"Start game", http.MethodPost, "/start-game", false, http.StatusPermanentRedirect, "/games"},
+		{"Grid action", http.MethodPost, "/handle-grid-action?action=flag_cell&row=1&col=2", true, http.StatusPermanentRedirect, "/games/uuid-1/moves?action=flag_cell&row=1&col=2"},
+		{"Grid action without session", http.MethodPost, "/handle-grid-action?action=flag_cell&row=1&col=2", false, http.StatusConflict, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(tc.method, tc.path, nil)
+			if tc.withSession {
+				request.AddCookie(sessionCookie(t, h, &models.Game{Uuid: "uuid-1"}))
+			}
+
+			recorder := serve(h, request)
+
+			if recorder.Code != tc.expectedStatus {
+				t.Errorf("Expected status %d, but got %d", tc.expectedStatus, recorder.Code)
+			}
+			if location := recorder.Header().Get("Location"); location != tc.expectedLocation {
+				t.Errorf("Expected location '%s', but got '%s'", tc.expectedLocation, location)
+			}
+		})
+	}
+}
+
+// recordingHTTPObserver records the served requests as "route status".
+type recordingHTTPObserver struct {
+	served []string
+}
+
+func (o *recordingHTTPObserver) RequestServed(r *http.Request, route string, status int, duration time.Duration) {
+	o.served = append(o.served, fmt.Sprintf("%s %d", route, status))
+}
+
+func TestInstrumentReportsRoutePatterns(t *testing.T) {
+	games := newFakeGameService()
+	h := newTestHandler(t, games)
+	games.games["uuid-1"] = &models.Game{Uuid: "uuid-1", GridSize: 4}
+
+	observer := &recordingHTTPObserver{}
+	instrumented := Instrument(observer, Routes(h, NewApiHandler(h.Templates, h.Store, h.Queries)))
+
+	testCases := []struct {
+		method   string
+		path     string
+		expected string
+	}{
+		{http.MethodGet, "/games/uuid-1", "GET /games/{uuid} 200"},
+		{http.MethodGet, "/games/unknown", "GET /games/{uuid} 200"},
+		{http.MethodGet, "/load-game?game_uuid=uuid-1", "GET /load-game 301"},
+		{http.MethodPut, "/games", UnmatchedRoute + " 405"},
+		{http.MethodGet, "/does-not-exist", UnmatchedRoute + " 404"},
+	}
+
+	for _, tc := range testCases {
+		instrumented.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tc.method, tc.path, nil))
+	}
+
+	for i, tc := range testCases {
+		if i >= len(observer.served) || observer.served[i] != tc.expected {
+			t.Errorf("Expected %s %s to be reported as %q, but got %q", tc.method, tc.path, tc.expected, observer.served)
+		}
+	}
+}
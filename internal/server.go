@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ServerConfig holds the timeouts of the HTTP server.
+type ServerConfig struct {
+	// ReadHeaderTimeout bounds reading the request headers, it keeps slow clients from holding connections.
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// ShutdownTimeout is how long in-flight requests get to finish once the server is asked to stop.
+	ShutdownTimeout time.Duration
+}
+
+// DefaultServerConfig returns timeouts that fit the small forms and pages of the game.
+func DefaultServerConfig() ServerConfig {
+	return ServerConfig{
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       15 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+		ShutdownTimeout:   15 * time.Second,
+	}
+}
+
+// NewServer returns a server listening on addr with the timeouts of config. Its errors, like failed TLS
+// handshakes, are logged as warnings of the default logger.
+func NewServer(addr string, handler http.Handler, config ServerConfig) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: config.ReadHeaderTimeout,
+		ReadTimeout:       config.ReadTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+}
+
+// Serve serves on listener until ctx is done, HTTPS when certFile and keyFile are set.
+//
+// Once ctx is done the server stops accepting connections and waits up to shutdownTimeout for the
+// in-flight requests, so a move that is being saved is not cut off. Requests still running after that are
+// aborted, their transactions are rolled back together with their canceled context.
+func Serve(ctx context.Context, server *http.Server, listener net.Listener, certFile, keyFile string, shutdownTimeout time.Duration) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		if certFile != "" || keyFile != "" {
+			serveErr <- server.ServeTLS(listener, certFile, keyFile)
+		} else {
+			serveErr <- server.Serve(listener)
+		}
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down, waiting for in-flight requests", "shutdown_timeout", shutdownTimeout.String())
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return errors.Join(fmt.Errorf("failed to drain in-flight requests: %w", err), server.Close())
+	}
+
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	slog.Info("Server stopped")
+	return nil
+}
@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// startSlowServer serves a handler that answers once release is closed, it returns the server address
+// and the result of Serve.
+func startSlowServer(t *testing.T, ctx context.Context, release <-chan struct{}, shutdownTimeout time.Duration) (string, <-chan struct{}, <-chan error) {
+	t.Helper()
+
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		w.Write([]byte("move saved"))
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	server := NewServer(listener.Addr().String(), handler, DefaultServerConfig())
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve(ctx, server, listener, "", "", shutdownTimeout)
+	}()
+
+	return "http://" + listener.Addr().String(), started, served
+}
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	release := make(chan struct{})
+	url, started, served := startSlowServer(t, ctx, release, 5*time.Second)
+
+	responses := make(chan string, 1)
+	go func() {
+		response, err := http.Get(url)
+		if err != nil {
+			responses <- err.Error()
+			return
+		}
+		defer response.Body.Close()
+		body, _ := io.ReadAll(response.Body)
+		responses <- string(body)
+	}()
+
+	<-started
+	cancel()
+
+	// the server stops accepting connections while the request is still running
+	time.Sleep(50 * time.Millisecond)
+	if _, err := http.Get(url); err == nil {
+		t.Errorf("Expected new requests to be refused during the shutdown")
+	}
+
+	close(release)
+
+	if body := <-responses; body != "move saved" {
+		t.Errorf("Expected the in-flight request to finish, but got %s", body)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("Expected a clean shutdown, but got %v", err)
+	}
+}
+
+func TestServeAbortsRequestsAfterShutdownTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	release := make(chan struct{})
+	defer close(release)
+	url, started, served := startSlowServer(t, ctx, release, 50*time.Millisecond)
+
+	go http.Get(url)
+
+	<-started
+	cancel()
+
+	select {
+	case err := <-served:
+		if err == nil {
+			t.Errorf("Expected an error for requests that did not finish in time")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected Serve to return after the shutdown timeout")
+	}
+}
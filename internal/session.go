@@ -1,23 +1,112 @@
 package internal
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"minesweeper/internal/models"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gorilla/sessions"
 )
 
+// sessionName is the name of the cookie holding the session of a player.
+const sessionName = "minesweeper-session"
+
+// sessionIDKey holds the random ID of the session, it tells sessions apart without revealing their content.
+const sessionIDKey = "session_id"
+
+// SessionConfig configures the cookie holding the session.
+type SessionConfig struct {
+	// Secrets sign the cookie. New cookies are signed with the first one and cookies signed with any of
+	// them are accepted, so a secret can be rotated by putting the new one in front of the old one.
+	Secrets []string
+	// Secure only sends the cookie over HTTPS.
+	Secure   bool
+	SameSite http.SameSite
+	// Domain is left empty to bind the cookie to the host that set it.
+	Domain string
+	MaxAge time.Duration
+}
+
+// NewSessionStore returns a cookie store configured by config.
+func NewSessionStore(config SessionConfig) (*sessions.CookieStore, error) {
+	if len(config.Secrets) == 0 {
+		return nil, errors.New("at least one session secret is required")
+	}
+	if config.MaxAge < time.Second {
+		return nil, fmt.Errorf("session max age must be at least a second, but got %v", config.MaxAge)
+	}
+	if config.SameSite == http.SameSiteNoneMode && !config.Secure {
+		return nil, errors.New("SameSite=None session cookies must be secure")
+	}
+
+	// hash and block key pairs, the cookies are only signed so every block key is nil
+	keyPairs := make([][]byte, 0, len(config.Secrets)*2)
+	for _, secret := range config.Secrets {
+		if secret == "" {
+			return nil, errors.New("session secrets must not be empty")
+		}
+		keyPairs = append(keyPairs, []byte(secret), nil)
+	}
+
+	store := sessions.NewCookieStore(keyPairs...)
+	store.Options = &sessions.Options{
+		Path:     "/",
+		Domain:   config.Domain,
+		HttpOnly: true,
+		Secure:   config.Secure,
+		SameSite: config.SameSite,
+	}
+	// also makes the cookies signed by the store expire after MaxAge, not only in the browser
+	store.MaxAge(int(config.MaxAge.Seconds()))
+
+	return store, nil
+}
+
+// ParseSameSite parses the SameSite mode of the session cookie, an empty value is lax.
+func ParseSameSite(value string) (http.SameSite, error) {
+	switch strings.ToLower(value) {
+	case "", "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	default:
+		return 0, fmt.Errorf("unknown SameSite mode %q, expected lax, strict or none", value)
+	}
+}
+
+// SessionID returns the ID of the session sent with r, or an empty string for a request without a session.
+// Sessions get their ID on their first safe request, see CSRF.
+func SessionID(r *http.Request, store *sessions.CookieStore) string {
+	session, err := store.Get(r, sessionName)
+	if err != nil {
+		return ""
+	}
+	sessionID, _ := session.Values[sessionIDKey].(string)
+	return sessionID
+}
+
+// ensureSessionID assigns session a new ID unless it has one, it tells whether the session has to be saved.
+func ensureSessionID(session *sessions.Session) bool {
+	if sessionID, _ := session.Values[sessionIDKey].(string); sessionID != "" {
+		return false
+	}
+	session.Values[sessionIDKey] = newRandomToken(16)
+	return true
+}
+
 func SaveGameToSession(w http.ResponseWriter, r *http.Request, game *models.Game, store *sessions.CookieStore) error {
 	if game.Uuid == "" {
 		return fmt.Errorf("game uuid is empty")
 	}
 
-	session, err := store.Get(r, "minesweeper-session")
-
+	session, err := store.Get(r, sessionName)
 	if err != nil {
-		log.Printf("Failed to get session: %v", err)
 		return fmt.Errorf("failed to get session: %w", err)
 	}
 
@@ -27,34 +116,30 @@ func SaveGameToSession(w http.ResponseWriter, r *http.Request, game *models.Game
 	if storedUuids, ok := session.Values["game_uuids"]; ok {
 		// Check type assertion
 		if uuids, ok = storedUuids.([]string); !ok {
-			log.Printf("Unexpected type for game_uuids: %T. Resetting UUIDs.", storedUuids)
+			slog.WarnContext(r.Context(), "Unexpected type of the game UUIDs in the session, resetting them", "type", fmt.Sprintf("%T", storedUuids))
 			uuids = []string{}
-		} else {
-			log.Printf("Retrieved existing game UUIDs from session: %v", uuids)
 		}
-	} else {
-		log.Printf("No existing game UUIDs found in session.")
 	}
 
-	if !contains(uuids, game.Uuid) {
+	added := !contains(uuids, game.Uuid)
+	if added {
 		uuids = append(uuids, game.Uuid)
 		session.Values["game_uuids"] = uuids
-		log.Printf("Added new game UUID to session: %s", game.Uuid)
-	} else {
-		log.Printf("Game UUID %s already exists in session, not adding.", game.Uuid)
 	}
 
+	// saved even when nothing changed, it renews the expiry of the cookie while the player is active
 	if err := session.Save(r, w); err != nil {
-		log.Printf("Failed to save session: %v", err)
 		return fmt.Errorf("failed to save session: %w", err)
 	}
 
-	log.Printf("Session saved successfully. Elements in slice: %d Updated game UUIDs in session: %+q", len(uuids), uuids)
+	if added {
+		slog.DebugContext(r.Context(), "Game added to session", "game_uuid", game.Uuid, "session_games", len(uuids))
+	}
 	return nil
 }
 
 func GetGameFromSession(r *http.Request, store *sessions.CookieStore) ([]string, error) {
-	session, err := store.Get(r, "minesweeper-session")
+	session, err := store.Get(r, sessionName)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,126 @@
+package internal
+
+import (
+	"minesweeper/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewSessionStoreRotatesSecrets(t *testing.T) {
+	oldStore, err := NewSessionStore(SessionConfig{Secrets: []string{"old-secret"}, MaxAge: time.Hour})
+	if err != nil {
+		t.Fatalf("Failed to create session store: %v", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	if err := SaveGameToSession(recorder, httptest.NewRequest(http.MethodGet, "/", nil), &models.Game{Uuid: "uuid-1"}, oldStore); err != nil {
+		t.Fatalf("Failed to save game to session: %v", err)
+	}
+	oldCookie := recorder.Result().Cookies()[0]
+
+	testCases := []struct {
+		name          string
+		secrets       []string
+		expectedGames int
+	}{
+		{"Old secret still accepted", []string{"new-secret", "old-secret"}, 1},
+		{"Old secret dropped", []string{"new-secret"}, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store, err := NewSessionStore(SessionConfig{Secrets: tc.secrets, MaxAge: time.Hour})
+			if err != nil {
+				t.Fatalf("Failed to create session store: %v", err)
+			}
+
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.AddCookie(oldCookie)
+
+			uuids, _ := GetGameFromSession(request, store)
+			if len(uuids) != tc.expectedGames {
+				t.Errorf("Expected %d games in the session, but got %v", tc.expectedGames, uuids)
+			}
+		})
+	}
+}
+
+func TestNewSessionStoreOptions(t *testing.T) {
+	store, err := NewSessionStore(SessionConfig{
+		Secrets:  []string{"secret"},
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+		Domain:   "minesweeper.example",
+		MaxAge:   2 * time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create session store: %v", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	if err := SaveGameToSession(recorder, httptest.NewRequest(http.MethodGet, "/", nil), &models.Game{Uuid: "uuid-1"}, store); err != nil {
+		t.Fatalf("Failed to save game to session: %v", err)
+	}
+	cookie := recorder.Result().Cookies()[0]
+
+	if !cookie.Secure || !cookie.HttpOnly {
+		t.Errorf("Expected a secure, HTTP only cookie, but got %+v", cookie)
+	}
+	if cookie.SameSite != http.SameSiteStrictMode {
+		t.Errorf("Expected SameSite=Strict, but got %v", cookie.SameSite)
+	}
+	if cookie.Domain != "minesweeper.example" {
+		t.Errorf("Expected domain minesweeper.example, but got %s", cookie.Domain)
+	}
+	if cookie.MaxAge != 7200 {
+		t.Errorf("Expected max age of 7200 seconds, but got %d", cookie.MaxAge)
+	}
+}
+
+func TestNewSessionStoreInvalidConfig(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config SessionConfig
+	}{
+		{"No secrets", SessionConfig{MaxAge: time.Hour}},
+		{"Empty secret", SessionConfig{Secrets: []string{"secret", ""}, MaxAge: time.Hour}},
+		{"No max age", SessionConfig{Secrets: []string{"secret"}}},
+		{"SameSite none without secure", SessionConfig{Secrets: []string{"secret"}, SameSite: http.SameSiteNoneMode, MaxAge: time.Hour}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := NewSessionStore(tc.config); err == nil {
+				t.Errorf("Expected an error for %+v", tc.config)
+			}
+		})
+	}
+}
+
+func TestParseSameSite(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected http.SameSite
+		wantErr  bool
+	}{
+		{"", http.SameSiteLaxMode, false},
+		{"Lax", http.SameSiteLaxMode, false},
+		{"strict", http.SameSiteStrictMode, false},
+		{"none", http.SameSiteNoneMode, false},
+		{"sometimes", 0, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			sameSite, err := ParseSameSite(tc.value)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Expected error %v, but got %v", tc.wantErr, err)
+			}
+			if sameSite != tc.expected {
+				t.Errorf("Expected %v, but got %v", tc.expected, sameSite)
+			}
+		})
+	}
+}
@@ -0,0 +1,662 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"minesweeper/internal/db"
+)
+
+// PostgresQueries implements Repository for PostgreSQL.
+//
+// The queries mirror db/queries/queries.sql, written in the PostgreSQL dialect,
+// and return the sqlc generated row types so callers don't see a difference.
+type PostgresQueries struct {
+	db db.DBTX
+}
+
+func NewPostgresQueries(dbtx db.DBTX) *PostgresQueries {
+	return &PostgresQueries{db: dbtx}
+}
+
+const gameColumns = `id, uuid, grid_size, mines_amount, game_failed, game_won, grid_state, created_at, win_rule, session_id, abandoned_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanGame(row rowScanner) (db.Game, error) {
+	var i db.Game
+	err := row.Scan(
+		&i.Id,
+		&i.Uuid,
+		&i.GridSize,
+		&i.MinesAmount,
+		&i.GameFailed,
+		&i.GameWon,
+		&i.GridState,
+		&i.CreatedAt,
+		&i.WinRule,
+		&i.SessionId,
+		&i.AbandonedAt,
+	)
+	return i, err
+}
+
+const pgCreateGame = `
+INSERT INTO
+    games (grid_size, mines_amount, grid_state, win_rule, session_id)
+VALUES
+    ($1, $2, $3, $4, $5) RETURNING ` + gameColumns
+
+func (q *PostgresQueries) CreateGame(ctx context.Context, arg db.CreateGameParams) (db.Game, error) {
+	row := q.db.QueryRowContext(ctx, pgCreateGame,
+		arg.GridSize,
+		arg.MinesAmount,
+		arg.GridState,
+		arg.WinRule,
+		arg.SessionId,
+	)
+	return scanGame(row)
+}
+
+const pgGetGameById = `
+SELECT ` + gameColumns + `
+FROM
+    games
+WHERE
+    id = $1`
+
+func (q *PostgresQueries) GetGameById(ctx context.Context, id int64) (db.Game, error) {
+	return scanGame(q.db.QueryRowContext(ctx, pgGetGameById, id))
+}
+
+const pgGetGameByUuid = `
+SELECT ` + gameColumns + `
+FROM
+    games
+WHERE
+    uuid = $1`
+
+func (q *PostgresQueries) GetGameByUuid(ctx context.Context, uuid string) (db.Game, error) {
+	return scanGame(q.db.QueryRowContext(ctx, pgGetGameByUuid, uuid))
+}
+
+const pgListGames = `
+SELECT
+    id, uuid, grid_size, mines_amount, game_failed, game_won, created_at
+FROM
+    games
+ORDER BY
+    created_at DESC
+LIMIT $1 OFFSET $2`
+
+func (q *PostgresQueries) ListGames(ctx context.Context, arg db.ListGamesParams) ([]db.ListGamesRow, error) {
+	rows, err := q.db.QueryContext(ctx, pgListGames, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []db.ListGamesRow
+	for rows.Next() {
+		var i db.ListGamesRow
+		if err := rows.Scan(
+			&i.Id,
+			&i.Uuid,
+			&i.GridSize,
+			&i.MinesAmount,
+			&i.GameFailed,
+			&i.GameWon,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const pgGetTotalGamesCount = `
+SELECT
+    COUNT(id) as count
+FROM
+    games`
+
+func (q *PostgresQueries) GetTotalGamesCount(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, pgGetTotalGamesCount)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+// pgGamesFilter is the WHERE clause of the admin list of games, shared by ListFilteredGames, CountFilteredGames
+// and ExportFilteredGames. It takes the filters as $1 to $8 in the order of pgGamesFilterArgs, the queries
+// number their own arguments from $9 on.
+const pgGamesFilter = `
+    (CAST($1 AS TEXT) IS NULL
+        OR CAST($1 AS TEXT) = CASE WHEN games.game_won THEN 'won' WHEN games.abandoned_at IS NOT NULL THEN 'abandoned' WHEN games.game_failed THEN 'lost' ELSE 'in_progress' END)
+    AND (games.grid_size >= $2 OR $2 IS NULL)
+    AND (games.grid_size <= $3 OR $3 IS NULL)
+    AND (games.mines_amount >= $4 OR $4 IS NULL)
+    AND (games.mines_amount <= $5 OR $5 IS NULL)
+    AND (games.created_at >= CAST(CAST($6 AS TEXT) AS TIMESTAMP) OR CAST($6 AS TEXT) IS NULL)
+    AND (games.created_at < CAST(CAST($7 AS TEXT) AS TIMESTAMP) OR CAST($7 AS TEXT) IS NULL)
+    AND (games.uuid LIKE CAST($8 AS TEXT) || '%' OR $8 IS NULL)`
+
+func pgGamesFilterArgs(filter db.CountFilteredGamesParams, args ...interface{}) []interface{} {
+	return append([]interface{}{
+		filter.Status,
+		filter.MinGridSize,
+		filter.MaxGridSize,
+		filter.MinMines,
+		filter.MaxMines,
+		filter.CreatedFrom,
+		filter.CreatedBefore,
+		filter.UuidPrefix,
+	}, args...)
+}
+
+const pgListFilteredGames = `
+SELECT
+    id, uuid, grid_size, mines_amount, game_failed, game_won, created_at, abandoned_at
+FROM
+    games
+WHERE` + pgGamesFilter + `
+    AND (CAST($10 AS TEXT) IS NULL
+        OR (CAST($9 AS TEXT) = 'created_at_desc'
+            AND (created_at, id) < (CAST(CAST($10 AS TEXT) AS TIMESTAMP), CAST($11 AS BIGINT)))
+        OR (CAST($9 AS TEXT) = 'created_at_asc'
+            AND (created_at, id) > (CAST(CAST($10 AS TEXT) AS TIMESTAMP), CAST($11 AS BIGINT))))
+ORDER BY
+    CASE WHEN CAST($9 AS TEXT) = 'id_asc' THEN id END ASC,
+    CASE WHEN CAST($9 AS TEXT) = 'id_desc' THEN id END DESC,
+    CASE WHEN CAST($9 AS TEXT) = 'uuid_asc' THEN uuid END ASC,
+    CASE WHEN CAST($9 AS TEXT) = 'uuid_desc' THEN uuid END DESC,
+    CASE WHEN CAST($9 AS TEXT) = 'grid_size_asc' THEN grid_size END ASC,
+    CASE WHEN CAST($9 AS TEXT) = 'grid_size_desc' THEN grid_size END DESC,
+    CASE WHEN CAST($9 AS TEXT) = 'mines_amount_asc' THEN mines_amount END ASC,
+    CASE WHEN CAST($9 AS TEXT) = 'mines_amount_desc' THEN mines_amount END DESC,
+    CASE WHEN CAST($9 AS TEXT) = 'status_asc' THEN CASE WHEN game_won THEN 'won' WHEN abandoned_at IS NOT NULL THEN 'abandoned' WHEN game_failed THEN 'lost' ELSE 'in_progress' END END ASC,
+    CASE WHEN CAST($9 AS TEXT) = 'status_desc' THEN CASE WHEN game_won THEN 'won' WHEN abandoned_at IS NOT NULL THEN 'abandoned' WHEN game_failed THEN 'lost' ELSE 'in_progress' END END DESC,
+    CASE WHEN CAST($9 AS TEXT) = 'created_at_asc' THEN created_at END ASC,
+    CASE WHEN CAST($9 AS TEXT) = 'created_at_asc' THEN id END ASC,
+    CASE WHEN CAST($9 AS TEXT) = 'created_at_desc' THEN created_at END DESC,
+    id DESC
+LIMIT $12 OFFSET $13`
+
+func (q *PostgresQueries) ListFilteredGames(ctx context.Context, arg db.ListFilteredGamesParams) ([]db.ListFilteredGamesRow, error) {
+	filter := db.CountFilteredGamesParams{
+		Status:        arg.Status,
+		MinGridSize:   arg.MinGridSize,
+		MaxGridSize:   arg.MaxGridSize,
+		MinMines:      arg.MinMines,
+		MaxMines:      arg.MaxMines,
+		CreatedFrom:   arg.CreatedFrom,
+		CreatedBefore: arg.CreatedBefore,
+		UuidPrefix:    arg.UuidPrefix,
+	}
+	rows, err := q.db.QueryContext(ctx, pgListFilteredGames, pgGamesFilterArgs(filter,
+		arg.Sort,
+		arg.CursorCreatedAt,
+		arg.CursorId,
+		arg.PageSize,
+		arg.PageOffset,
+	)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []db.ListFilteredGamesRow
+	for rows.Next() {
+		var i db.ListFilteredGamesRow
+		if err := rows.Scan(
+			&i.Id,
+			&i.Uuid,
+			&i.GridSize,
+			&i.MinesAmount,
+			&i.GameFailed,
+			&i.GameWon,
+			&i.CreatedAt,
+			&i.AbandonedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const pgCountFilteredGames = `
+SELECT
+    COUNT(id) AS count
+FROM
+    games
+WHERE` + pgGamesFilter
+
+func (q *PostgresQueries) CountFilteredGames(ctx context.Context, arg db.CountFilteredGamesParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, pgCountFilteredGames, pgGamesFilterArgs(arg)...)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const pgExportFilteredGames = `
+SELECT
+    games.id, games.uuid, games.grid_size, games.mines_amount, games.game_failed, games.game_won, games.grid_state,
+    games.created_at, games.win_rule, games.session_id, games.abandoned_at,
+    (SELECT COUNT(*) FROM moves WHERE moves.game_id = games.id) AS moves_count,
+    last_move.create_at AS last_move_at
+FROM
+    games
+    LEFT JOIN moves AS last_move ON last_move.id = (SELECT MAX(moves.id) FROM moves WHERE moves.game_id = games.id)
+WHERE` + pgGamesFilter + `
+    AND (CAST($9 AS TEXT) IS NULL OR games.uuid IN (SELECT jsonb_array_elements_text(CAST($9 AS JSONB))))
+    AND games.id > $10
+ORDER BY
+    games.id
+LIMIT
+    $11`
+
+func (q *PostgresQueries) ExportFilteredGames(ctx context.Context, arg db.ExportFilteredGamesParams) ([]db.ExportFilteredGamesRow, error) {
+	filter := db.CountFilteredGamesParams{
+		Status:        arg.Status,
+		MinGridSize:   arg.MinGridSize,
+		MaxGridSize:   arg.MaxGridSize,
+		MinMines:      arg.MinMines,
+		MaxMines:      arg.MaxMines,
+		CreatedFrom:   arg.CreatedFrom,
+		CreatedBefore: arg.CreatedBefore,
+		UuidPrefix:    arg.UuidPrefix,
+	}
+	rows, err := q.db.QueryContext(ctx, pgExportFilteredGames, pgGamesFilterArgs(filter,
+		arg.Uuids,
+		arg.AfterId,
+		arg.PageSize,
+	)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []db.ExportFilteredGamesRow
+	for rows.Next() {
+		var i db.ExportFilteredGamesRow
+		if err := rows.Scan(
+			&i.Id,
+			&i.Uuid,
+			&i.GridSize,
+			&i.MinesAmount,
+			&i.GameFailed,
+			&i.GameWon,
+			&i.GridState,
+			&i.CreatedAt,
+			&i.WinRule,
+			&i.SessionId,
+			&i.AbandonedAt,
+			&i.MovesCount,
+			&i.LastMoveAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const pgUpdateGameGridStateById = `
+UPDATE
+    games
+SET
+    game_failed = $1,
+    game_won = $2,
+    grid_state = $3
+WHERE
+    id = $4
+    AND grid_state = $5
+    AND game_failed = FALSE
+    AND game_won = FALSE`
+
+func (q *PostgresQueries) UpdateGameGridStateById(ctx context.Context, arg db.UpdateGameGridStateByIdParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, pgUpdateGameGridStateById,
+		arg.GameFailed,
+		arg.GameWon,
+		arg.GridState,
+		arg.Id,
+		arg.OldGridState,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const pgListLegacyGridStates = `
+SELECT
+    id, grid_size, grid_state
+FROM
+    games
+WHERE
+    id > $1 AND substr(grid_state, 1, 1) <> '~'
+ORDER BY
+    id
+LIMIT $2`
+
+func (q *PostgresQueries) ListLegacyGridStates(ctx context.Context, arg db.ListLegacyGridStatesParams) ([]db.ListLegacyGridStatesRow, error) {
+	rows, err := q.db.QueryContext(ctx, pgListLegacyGridStates, arg.Id, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []db.ListLegacyGridStatesRow
+	for rows.Next() {
+		var i db.ListLegacyGridStatesRow
+		if err := rows.Scan(&i.Id, &i.GridSize, &i.GridState); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const pgReplaceGameGridState = `
+UPDATE
+    games
+SET
+    grid_state = $1
+WHERE
+    id = $2 AND grid_state = $3`
+
+func (q *PostgresQueries) ReplaceGameGridState(ctx context.Context, arg db.ReplaceGameGridStateParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, pgReplaceGameGridState, arg.NewGridState, arg.Id, arg.OldGridState)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const pgInsertMove = `
+INSERT INTO
+    moves (game_id, move_type, "row", col)
+VALUES
+    ($1, $2, $3, $4) RETURNING id, game_id, move_type, "row", col, create_at`
+
+func (q *PostgresQueries) InsertMove(ctx context.Context, arg db.InsertMoveParams) (db.Move, error) {
+	row := q.db.QueryRowContext(ctx, pgInsertMove,
+		arg.GameId,
+		arg.MoveType,
+		arg.Row,
+		arg.Col,
+	)
+	var i db.Move
+	err := row.Scan(
+		&i.Id,
+		&i.GameId,
+		&i.MoveType,
+		&i.Row,
+		&i.Col,
+		&i.CreateAt,
+	)
+	return i, err
+}
+
+const pgGetMovesByGameId = `
+SELECT
+    id, game_id, move_type, "row", col, create_at
+FROM
+    moves
+WHERE
+    game_id = $1
+ORDER BY
+    id`
+
+func (q *PostgresQueries) GetMovesByGameId(ctx context.Context, gameID int64) ([]db.Move, error) {
+	rows, err := q.db.QueryContext(ctx, pgGetMovesByGameId, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []db.Move
+	for rows.Next() {
+		var i db.Move
+		if err := rows.Scan(
+			&i.Id,
+			&i.GameId,
+			&i.MoveType,
+			&i.Row,
+			&i.Col,
+			&i.CreateAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const pgGetGamesInfo = `
+SELECT
+    COUNT(*) AS total_games,
+    COUNT(*) FILTER (WHERE game_won = TRUE) AS won_games,
+    COUNT(*) FILTER (WHERE game_failed = TRUE AND game_won = FALSE) AS lost_games,
+    COUNT(*) FILTER (WHERE game_failed = FALSE AND game_won = FALSE) AS not_finished_games
+FROM
+    games
+WHERE
+    abandoned_at IS NULL`
+
+func (q *PostgresQueries) GetGamesInfo(ctx context.Context) (db.GetGamesInfoRow, error) {
+	row := q.db.QueryRowContext(ctx, pgGetGamesInfo)
+	var i db.GetGamesInfoRow
+	err := row.Scan(
+		&i.TotalGames,
+		&i.WonGames,
+		&i.LostGames,
+		&i.NotFinishedGames,
+	)
+	return i, err
+}
+
+const pgGetGamesInfoByUuids = `
+SELECT
+    COUNT(*) AS total_games,
+    COUNT(*) FILTER (WHERE game_won = TRUE) AS won_games,
+    COUNT(*) FILTER (WHERE game_failed = TRUE AND game_won = FALSE) AS lost_games,
+    COUNT(*) FILTER (WHERE game_failed = FALSE AND game_won = FALSE) AS not_finished_games
+FROM
+    games
+WHERE
+    uuid = ANY($1::TEXT[]) AND abandoned_at IS NULL`
+
+func (q *PostgresQueries) GetGamesInfoByUuids(ctx context.Context, uuids []string) (db.GetGamesInfoByUuidsRow, error) {
+	// pgx sends a nil slice as NULL, which would not match the empty array semantics of the SQLite query
+	if uuids == nil {
+		uuids = []string{}
+	}
+
+	row := q.db.QueryRowContext(ctx, pgGetGamesInfoByUuids, uuids)
+	var i db.GetGamesInfoByUuidsRow
+	err := row.Scan(
+		&i.TotalGames,
+		&i.WonGames,
+		&i.LostGames,
+		&i.NotFinishedGames,
+	)
+	return i, err
+}
+
+const pgGetGamesByMonthYearGroupedByDay = `
+SELECT
+    to_char(created_at, 'DD') AS day,
+    COUNT(*) AS games_played
+FROM games
+WHERE created_at >= $1 AND created_at < $2
+GROUP BY day
+ORDER BY day`
+
+func (q *PostgresQueries) GetGamesByMonthYearGroupedByDay(ctx context.Context, arg db.GetGamesByMonthYearGroupedByDayParams) ([]db.GetGamesByMonthYearGroupedByDayRow, error) {
+	rows, err := q.db.QueryContext(ctx, pgGetGamesByMonthYearGroupedByDay, arg.CreatedAt, arg.CreatedAt_2)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []db.GetGamesByMonthYearGroupedByDayRow
+	for rows.Next() {
+		var i db.GetGamesByMonthYearGroupedByDayRow
+		if err := rows.Scan(&i.Day, &i.GamesPlayed); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const pgGetGamesPlayedPerGridSize = `
+SELECT grid_size, COUNT(*) AS games_played
+FROM games
+GROUP BY grid_size
+ORDER BY grid_size`
+
+func (q *PostgresQueries) GetGamesPlayedPerGridSize(ctx context.Context) ([]db.GetGamesPlayedPerGridSizeRow, error) {
+	rows, err := q.db.QueryContext(ctx, pgGetGamesPlayedPerGridSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []db.GetGamesPlayedPerGridSizeRow
+	for rows.Next() {
+		var i db.GetGamesPlayedPerGridSizeRow
+		if err := rows.Scan(&i.GridSize, &i.GamesPlayed); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const pgGetMinesPopularity = `
+SELECT mines_amount, COUNT(*) AS mines_count
+FROM games
+GROUP BY mines_amount
+ORDER BY mines_amount`
+
+func (q *PostgresQueries) GetMinesPopularity(ctx context.Context) ([]db.GetMinesPopularityRow, error) {
+	rows, err := q.db.QueryContext(ctx, pgGetMinesPopularity)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []db.GetMinesPopularityRow
+	for rows.Next() {
+		var i db.GetMinesPopularityRow
+		if err := rows.Scan(&i.MinesAmount, &i.MinesCount); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const pgAbandonGameByUuid = `
+UPDATE
+    games
+SET
+    game_failed = TRUE,
+    abandoned_at = CURRENT_TIMESTAMP
+WHERE
+    uuid = $1 AND game_failed = FALSE AND game_won = FALSE
+RETURNING ` + gameColumns
+
+func (q *PostgresQueries) AbandonGameByUuid(ctx context.Context, uuid string) (db.Game, error) {
+	return scanGame(q.db.QueryRowContext(ctx, pgAbandonGameByUuid, uuid))
+}
+
+const pgDeleteGameById = `
+DELETE FROM
+    games
+WHERE
+    id = $1`
+
+func (q *PostgresQueries) DeleteGameById(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, pgDeleteGameById, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const pgAbandonUnfinishedGamesCreatedBefore = `
+UPDATE
+    games
+SET
+    game_failed = TRUE,
+    abandoned_at = CURRENT_TIMESTAMP
+WHERE
+    created_at < $1 AND game_failed = FALSE AND game_won = FALSE`
+
+func (q *PostgresQueries) AbandonUnfinishedGamesCreatedBefore(ctx context.Context, createdBefore sql.NullTime) (int64, error) {
+	result, err := q.db.ExecContext(ctx, pgAbandonUnfinishedGamesCreatedBefore, createdBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const pgDeleteUnfinishedGamesCreatedBefore = `
+DELETE FROM
+    games
+WHERE
+    created_at < $1 AND game_failed = FALSE AND game_won = FALSE`
+
+func (q *PostgresQueries) DeleteUnfinishedGamesCreatedBefore(ctx context.Context, createdBefore sql.NullTime) (int64, error) {
+	result, err := q.db.ExecContext(ctx, pgDeleteUnfinishedGamesCreatedBefore, createdBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,179 @@
+// Package storage hides which database the games are kept in.
+//
+// The handlers only talk to a Repository. For SQLite it is the sqlc generated *db.Queries,
+// for PostgreSQL it is *PostgresQueries, which returns the same row types.
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"minesweeper/db/migrations"
+	"minesweeper/internal/db"
+	"strings"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// GameRepository stores the games and their grid states.
+type GameRepository interface {
+	CreateGame(ctx context.Context, arg db.CreateGameParams) (db.Game, error)
+	GetGameById(ctx context.Context, id int64) (db.Game, error)
+	GetGameByUuid(ctx context.Context, uuid string) (db.Game, error)
+	ListGames(ctx context.Context, arg db.ListGamesParams) ([]db.ListGamesRow, error)
+	GetTotalGamesCount(ctx context.Context) (int64, error)
+	ListFilteredGames(ctx context.Context, arg db.ListFilteredGamesParams) ([]db.ListFilteredGamesRow, error)
+	CountFilteredGames(ctx context.Context, arg db.CountFilteredGamesParams) (int64, error)
+	ExportFilteredGames(ctx context.Context, arg db.ExportFilteredGamesParams) ([]db.ExportFilteredGamesRow, error)
+	UpdateGameGridStateById(ctx context.Context, arg db.UpdateGameGridStateByIdParams) (int64, error)
+	ListLegacyGridStates(ctx context.Context, arg db.ListLegacyGridStatesParams) ([]db.ListLegacyGridStatesRow, error)
+	ReplaceGameGridState(ctx context.Context, arg db.ReplaceGameGridStateParams) (int64, error)
+	AbandonGameByUuid(ctx context.Context, uuid string) (db.Game, error)
+	DeleteGameById(ctx context.Context, id int64) (int64, error)
+	AbandonUnfinishedGamesCreatedBefore(ctx context.Context, createdBefore sql.NullTime) (int64, error)
+	DeleteUnfinishedGamesCreatedBefore(ctx context.Context, createdBefore sql.NullTime) (int64, error)
+}
+
+// MoveRepository stores the moves made in the games.
+type MoveRepository interface {
+	InsertMove(ctx context.Context, arg db.InsertMoveParams) (db.Move, error)
+	GetMovesByGameId(ctx context.Context, gameID int64) ([]db.Move, error)
+}
+
+// StatsRepository computes the statistics shown in the session info and on the charts.
+type StatsRepository interface {
+	GetGamesInfo(ctx context.Context) (db.GetGamesInfoRow, error)
+	GetGamesInfoByUuids(ctx context.Context, uuids []string) (db.GetGamesInfoByUuidsRow, error)
+	GetGamesByMonthYearGroupedByDay(ctx context.Context, arg db.GetGamesByMonthYearGroupedByDayParams) ([]db.GetGamesByMonthYearGroupedByDayRow, error)
+	GetGamesPlayedPerGridSize(ctx context.Context) ([]db.GetGamesPlayedPerGridSizeRow, error)
+	GetMinesPopularity(ctx context.Context) ([]db.GetMinesPopularityRow, error)
+}
+
+type Repository interface {
+	GameRepository
+	MoveRepository
+	StatsRepository
+}
+
+// Transactor runs a group of repository calls atomically.
+type Transactor interface {
+	// InTx calls fn with a Repository bound to a new transaction. The transaction is committed
+	// when fn returns nil and rolled back when it returns an error, which is passed on.
+	InTx(ctx context.Context, fn func(repository Repository) error) error
+}
+
+var (
+	_ Repository = (*db.Queries)(nil)
+	_ Repository = (*PostgresQueries)(nil)
+	_ Transactor = (*Database)(nil)
+)
+
+// Database is an open connection pool together with the dialect it speaks.
+type Database struct {
+	*sql.DB
+	Dialect string
+}
+
+// ParseDatabaseURL picks the dialect, driver and data source name from a DATABASE_URL.
+//
+// postgres:// and postgresql:// URLs are passed to the pgx driver as they are.
+// sqlite:// URLs and plain file paths, which is how DATABASE_URL was always set, open a SQLite database.
+func ParseDatabaseURL(databaseURL string) (dialect string, driverName string, dataSourceName string, err error) {
+	scheme, rest, hasScheme := strings.Cut(databaseURL, "://")
+	if !hasScheme {
+		return migrations.DialectSQLite, "sqlite", databaseURL, nil
+	}
+
+	switch scheme {
+	case "postgres", "postgresql":
+		return migrations.DialectPostgres, "pgx", databaseURL, nil
+	case "sqlite", "sqlite3":
+		return migrations.DialectSQLite, "sqlite", rest, nil
+	default:
+		return "", "", "", fmt.Errorf("unsupported DATABASE_URL scheme %q", scheme)
+	}
+}
+
+// Open opens the database described by databaseURL and checks that it is reachable.
+func Open(databaseURL string) (*Database, error) {
+	dialect, driverName, dataSourceName, err := ParseDatabaseURL(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if dialect == migrations.DialectSQLite {
+		dataSourceName = withSQLiteForeignKeys(dataSourceName)
+	}
+
+	dbConn, err := sql.Open(driverName, dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if the database is accessible
+	if err := dbConn.Ping(); err != nil {
+		dbConn.Close()
+		return nil, err
+	}
+
+	return &Database{DB: dbConn, Dialect: dialect}, nil
+}
+
+// withSQLiteForeignKeys turns on the foreign keys of every SQLite connection, SQLite ignores them by default,
+// which would leave the moves of deleted games behind instead of cascading.
+func withSQLiteForeignKeys(dataSourceName string) string {
+	if strings.Contains(dataSourceName, "foreign_keys") {
+		return dataSourceName
+	}
+
+	separator := "?"
+	if strings.Contains(dataSourceName, "?") {
+		separator = "&"
+	}
+	return dataSourceName + separator + "_pragma=foreign_keys(1)"
+}
+
+// NewRepository returns the Repository implementation for the dialect on top of a connection or transaction.
+func NewRepository(dialect string, dbtx db.DBTX) Repository {
+	if dialect == migrations.DialectPostgres {
+		return NewPostgresQueries(dbtx)
+	}
+	return db.New(dbtx)
+}
+
+// Repository returns the Repository implementation matching the database dialect.
+func (d *Database) Repository() Repository {
+	return NewRepository(d.Dialect, d.DB)
+}
+
+func (d *Database) InTx(ctx context.Context, fn func(repository Repository) error) error {
+	tx, err := d.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := fn(NewRepository(d.Dialect, tx)); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return errors.Join(err, fmt.Errorf("failed to roll back transaction: %w", rollbackErr))
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Migrate applies the pending embedded migrations of the database dialect.
+func (d *Database) Migrate(ctx context.Context) (before migrations.SchemaVersion, after migrations.SchemaVersion, err error) {
+	return migrations.Up(ctx, d.DB, d.Dialect)
+}
+
+// CheckMigrations verifies that every embedded migration has been applied, without applying any.
+func (d *Database) CheckMigrations(ctx context.Context) (migrations.SchemaVersion, error) {
+	return migrations.Check(ctx, d.DB, d.Dialect)
+}
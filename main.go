@@ -1,133 +1,192 @@
 package main
 
 import (
-	"cmp"
-	"database/sql"
+	"context"
+	"flag"
 	"fmt"
 	"html/template"
-	"log"
+	"io"
+	"log/slog"
 	"minesweeper/internal"
-	"minesweeper/internal/db"
-	"net/http"
+	"minesweeper/internal/config"
+	"minesweeper/internal/game"
+	"minesweeper/internal/metrics"
+	"minesweeper/internal/storage"
+	"net"
 	"os"
+	"os/signal"
+	"syscall"
 
-	_ "modernc.org/sqlite"
-
-	"github.com/gorilla/sessions"
 	"github.com/joho/godotenv"
 )
 
-// Package-level variable to store the parsed templates
-var templates *template.Template
-
-var globalStore *sessions.CookieStore
-
-func init() {
-	var err error
-
-	// Parse templates from the "public" and "public/**" directories
+// parseTemplates parses the templates from the "templates" and "templates/**" directories.
+func parseTemplates() (*template.Template, error) {
 	funcMap := template.FuncMap{
 		"Sub": func(a int, b int) int { return a - b },
 		"Add": func(a int, b int) int { return a + b },
 	}
 
-	templates, err = template.New("").Funcs(funcMap).ParseGlob("templates/*.html")
+	templates, err := template.New("").Funcs(funcMap).ParseGlob("templates/*.html")
 	if err != nil {
-		log.Fatalf("Error parsing templates: %v", err)
+		return nil, fmt.Errorf("error parsing templates: %w", err)
 	}
 
 	templates, err = templates.New("").Funcs(funcMap).ParseGlob("templates/**/*.html")
 	if err != nil {
-		log.Fatalf("Error parsing components: %v", err)
+		return nil, fmt.Errorf("error parsing components: %w", err)
 	}
 
-	// Log the templates that have been parsed
 	for _, tmpl := range templates.Templates() {
-		log.Printf("Parsed template: %s", tmpl.Name())
+		slog.Debug("Parsed template", "template", tmpl.Name())
 	}
 
-	fmt.Println("Trying to load .env file...")
-	envErr := godotenv.Load(".env")
-	if envErr != nil {
-		log.Println(".env file not found. Continuing with environment variables.")
-	}
+	return templates, nil
+}
 
-	// Now load the SESSION_SECRET from environment variables
-	sessionSecret := os.Getenv("SESSION_SECRET")
-	if sessionSecret == "" {
-		log.Fatal("SESSION_SECRET environment variable not set. The application cannot start without it.")
+// openLogOutput opens the log destination, stdout and stderr name the standard streams,
+// anything else is a file the logs are appended to.
+func openLogOutput(destination string) (io.WriteCloser, error) {
+	switch destination {
+	case "stdout":
+		return nopCloser{os.Stdout}, nil
+	case "stderr":
+		return nopCloser{os.Stderr}, nil
+	default:
+		return os.OpenFile(destination, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
 	}
+}
 
-	globalStore = sessions.NewCookieStore([]byte(sessionSecret))
-	// TODO fix this so it will also work with HTTPS
-	globalStore.Options = &sessions.Options{
-		Path:     "/",
-		HttpOnly: true,
-		MaxAge:   3600,  // seconds
-		Secure:   false, // Set to true if using HTTPS
-	}
-	log.Println("Session store initialized successfully.")
+type nopCloser struct {
+	io.Writer
 }
 
-func connectToDB() (*sql.DB, error) {
-	databaseURL := os.Getenv("DATABASE_URL")
-	if databaseURL == "" {
-		return nil, fmt.Errorf("DATABASE_URL environment variable not set")
-	}
+func (nopCloser) Close() error {
+	return nil
+}
 
-	db, err := sql.Open("sqlite", databaseURL)
+// migrateDB applies any pending embedded migrations, so a fresh or outdated DATABASE_URL is usable right away.
+func migrateDB(database *storage.Database) error {
+	before, after, err := database.Migrate(context.Background())
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	// Check if the database is accessible
-	if err := db.Ping(); err != nil {
-		return nil, err
+	if before.Current != after.Current {
+		slog.Info("Database schema migrated", "dialect", database.Dialect, "from_version", before.Current, "to_version", after.Current)
+	} else {
+		slog.Info("Database schema is up to date", "dialect", database.Dialect, "version", after.Current)
 	}
 
-	return db, nil
+	return nil
 }
 
 func main() {
-	logFile, err := os.OpenFile("minesweeper.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run starts the server and blocks until it stopped. Errors are returned instead of exiting right away,
+// so the deferred closing of the database and the log file always runs.
+func run() error {
+	migrateOnly := flag.Bool("migrate-only", false, "apply pending database migrations and exit")
+
+	// logged once the logger is set up, the .env file may configure it
+	envFileErr := godotenv.Load(".env")
+
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:], os.LookupEnv)
 	if err != nil {
-		log.Fatalf("Failed to open log file: %v", err)
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	logOutput, err := openLogOutput(cfg.LogFile)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer logOutput.Close()
+
+	// the default logger also receives what is still written through the log package
+	logger := internal.NewLogger(logOutput, cfg.LogLevel)
+	slog.SetDefault(logger)
+
+	if envFileErr != nil {
+		slog.Debug(".env file not loaded, continuing with environment variables", "error", envFileErr)
 	}
-	defer logFile.Close()
-	log.SetOutput(logFile)
 
-	mux := http.NewServeMux()
+	templates, err := parseTemplates()
+	if err != nil {
+		return err
+	}
 
-	staticFiles := http.StripPrefix("/dist/", http.FileServer(http.Dir("dist")))
-	mux.Handle("/dist/", staticFiles)
+	store, err := internal.NewSessionStore(cfg.Session)
+	if err != nil {
+		return fmt.Errorf("failed to initialize session store: %w", err)
+	}
 
-	dbConn, err := connectToDB()
+	database, err := storage.Open(cfg.DatabaseURL)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer func() {
+		if err := database.Close(); err != nil {
+			slog.Error("Failed to close database", "error", err)
+		}
+	}()
+
+	if err := migrateDB(database); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
 	}
-	defer dbConn.Close()
 
-	queries := db.New(dbConn)
-	handler := internal.NewHandler(templates, globalStore, queries)
-	apiHandler := internal.NewApiHandler(templates, globalStore, queries)
+	if *migrateOnly {
+		fmt.Println("Database migrations applied.")
+		return nil
+	}
 
-	mux.HandleFunc("/", handler.Index)
-	mux.HandleFunc("/load-game", handler.LoadGame)
-	mux.HandleFunc("/start-game", handler.StartGame)
-	mux.HandleFunc("/handle-grid-action", handler.HandleGridAction)
-	mux.HandleFunc("/games", handler.IndexGames)
-	mux.HandleFunc("/session-games-info", handler.SessionGamesInfo)
-	mux.HandleFunc("/charts", handler.Charts)
+	serverMetrics := metrics.New()
+	if err := serverMetrics.RegisterDB(database.DB); err != nil {
+		return fmt.Errorf("failed to register database metrics: %w", err)
+	}
 
-	mux.HandleFunc("/api/charts/pie/wins-losses-incomplete", apiHandler.PieWinsLossesIncompleteChart)
-	mux.HandleFunc("/api/charts/bar/grid-size", apiHandler.GridSizeBar)
-	mux.HandleFunc("/api/charts/bar/mines-amount", apiHandler.MinesAmountBarChart)
-	mux.HandleFunc("/api/charts/bar/games-played", apiHandler.PlayedGamesInMonthBarChart)
+	queries := database.Repository()
+	games := game.NewService(queries, database, serverMetrics)
+	handler := internal.NewHandler(templates, store, queries, games, cfg.Game, cfg.Admin)
+	switch {
+	case cfg.Admin.Enabled():
+	case cfg.Admin.Insecure:
+		slog.Warn("ADMIN_PASSWORD is not set and ADMIN_INSECURE is on, the admin area is open but only shows redacted game UUIDs")
+	default:
+		slog.Warn("ADMIN_PASSWORD is not set, the admin area is disabled")
+	}
+	apiHandler := internal.NewApiHandler(templates, store, queries)
 
-	port := cmp.Or(os.Getenv("APP_PORT"), "8080")
+	health := internal.NewHealthHandler(database, templates)
 
-	fmt.Printf("Server is listening on port %s...\n", port)
-	if err := http.ListenAndServe(":"+port, mux); err != nil {
-		fmt.Printf("Failed to start server: %v\n", err)
+	mux := internal.Routes(handler, apiHandler)
+	limiter := internal.NewRateLimiter(cfg.RateLimit)
+	app := internal.RateLimit(limiter, store, templates, mux)(internal.CSRF(store, templates)(mux))
+	appHandler := internal.RequestID(internal.Instrument(internal.HTTPObservers{serverMetrics, internal.NewAccessLog(logger)}, internal.ProbeRoutes(health, serverMetrics.Handler(), app)))
+
+	server := internal.NewServer(":"+cfg.Port, appHandler, cfg.Server)
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to start server: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	if cfg.Retention.Enabled() {
+		go games.RunRetention(ctx, cfg.Retention)
 	}
+
+	slog.Info("Server is listening", "port", cfg.Port, "https", cfg.TLS.Enabled())
+
+	// every move is committed before its response is sent, so draining the requests leaves no game state behind
+	if err := internal.Serve(ctx, server, listener, cfg.TLS.CertFile, cfg.TLS.KeyFile, cfg.Server.ShutdownTimeout); err != nil {
+		return fmt.Errorf("server stopped: %w", err)
+	}
+
+	return nil
 }